@@ -0,0 +1,313 @@
+package execx
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEnvNotFound     = errors.New("EnvNotFound")
+	ErrInvalidBinding  = errors.New("InvalidBinding")
+	ErrUnsupportedType = errors.New("UnsupportedType")
+)
+
+// lookup returns the value passed to commands.
+func (e Env) lookup(key string) (string, error) {
+	v, ok := e.Get(key)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrEnvNotFound, key)
+	}
+	if e.Mode(key) == SetLazy {
+		v = e.Expand(v)
+	}
+	return v, nil
+}
+
+// GetInt returns the value of the variable as an int.
+func (e Env) GetInt(key string) (int, error) {
+	v, err := e.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	x, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("%w: env %s", err, key)
+	}
+	return x, nil
+}
+
+// GetBool returns the value of the variable as a bool, see [strconv.ParseBool].
+func (e Env) GetBool(key string) (bool, error) {
+	v, err := e.lookup(key)
+	if err != nil {
+		return false, err
+	}
+	x, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return false, fmt.Errorf("%w: env %s", err, key)
+	}
+	return x, nil
+}
+
+// GetDuration returns the value of the variable as a [time.Duration], see [time.ParseDuration].
+func (e Env) GetDuration(key string) (time.Duration, error) {
+	v, err := e.lookup(key)
+	if err != nil {
+		return 0, err
+	}
+	x, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("%w: env %s", err, key)
+	}
+	return x, nil
+}
+
+// GetList returns the value of the variable split by sep.
+// Elements are trimmed, and an empty value is an empty list.
+func (e Env) GetList(key, sep string) ([]string, error) {
+	v, err := e.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return splitList(v, sep), nil
+}
+
+func splitList(v, sep string) []string {
+	if strings.TrimSpace(v) == "" {
+		return []string{}
+	}
+	xs := strings.Split(v, sep)
+	for i, x := range xs {
+		xs[i] = strings.TrimSpace(x)
+	}
+	return xs
+}
+
+type bindField struct {
+	name     string
+	required bool
+	secret   bool
+	sep      string
+	def      string
+	hasDef   bool
+	value    reflect.Value
+	field    reflect.StructField
+}
+
+func parseBindFields(v reflect.Value) []*bindField {
+	var (
+		t      = v.Type()
+		result []*bindField
+	)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("env")
+		if !ok || tag == "-" || !f.IsExported() {
+			continue
+		}
+		xs := strings.Split(tag, ",")
+		b := &bindField{
+			name:  xs[0],
+			sep:   ",",
+			value: v.Field(i),
+			field: f,
+		}
+		if b.name == "" {
+			b.name = f.Name
+		}
+		for _, x := range xs[1:] {
+			switch strings.TrimSpace(x) {
+			case "required":
+				b.required = true
+			case "secret":
+				b.secret = true
+			}
+		}
+		if s, ok := f.Tag.Lookup("sep"); ok && s != "" {
+			b.sep = s
+		}
+		b.def, b.hasDef = f.Tag.Lookup("default")
+		result = append(result, b)
+	}
+	return result
+}
+
+// Bind sets the values of the variables to the fields of v, a pointer to a struct.
+//
+// Fields are bound by tags:
+//
+//	type Config struct {
+//		Host    string        `env:"HOST"`
+//		Port    int           `env:"PORT,required"`
+//		Debug   bool          `env:"DEBUG" default:"false"`
+//		Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+//		Tags    []string      `env:"TAGS" sep:":"`
+//		Token   string        `env:"TOKEN,secret"`
+//	}
+//
+// Supported types are string, bool, integers, floats, [time.Duration], []string
+// and [encoding.TextUnmarshaler].
+// Fields of unset variables are not changed unless they have defaults.
+// Returns all errors of the fields.
+func (e Env) Bind(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: bind requires a pointer to a struct, got %T", ErrInvalidBinding, v)
+	}
+
+	var errs []error
+	for _, f := range parseBindFields(rv.Elem()) {
+		value, err := e.lookup(f.name)
+		switch {
+		case err == nil:
+		case f.hasDef:
+			value = f.def
+		case f.required:
+			errs = append(errs, fmt.Errorf("%w: field %s", err, f.field.Name))
+			continue
+		default:
+			continue
+		}
+		if err := setBindValue(f.value, value, f.sep); err != nil {
+			errs = append(errs, fmt.Errorf("%w: field %s from env %s", err, f.field.Name, f.name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	durationType        = reflect.TypeFor[time.Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func setBindValue(v reflect.Value, value, sep string) error {
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	if v.Type() == durationType {
+		x, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(x))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		x, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		v.SetBool(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := strconv.ParseInt(strings.TrimSpace(value), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(strings.TrimSpace(value), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(strings.TrimSpace(value), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		xs := splitList(value, sep)
+		s := reflect.MakeSlice(v.Type(), len(xs), len(xs))
+		for i, x := range xs {
+			s.Index(i).SetString(x)
+		}
+		v.Set(s)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return nil
+}
+
+// EnvFromStruct creates a new [Env] from the fields of v, a struct or a pointer to a struct.
+//
+// Fields are bound by the same tags as [Env.Bind], the variables of the secret fields are secret.
+func EnvFromStruct(v any) (Env, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return Env{}, fmt.Errorf("%w: requires a struct, got %T", ErrInvalidBinding, v)
+	}
+
+	var (
+		env  = NewEnv()
+		errs []error
+	)
+	for _, f := range parseBindFields(rv) {
+		value, err := formatBindValue(f.value, f.sep)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: field %s to env %s", err, f.field.Name, f.name))
+			continue
+		}
+		env.SetWithMode(f.name, value, SetRaw)
+		if f.secret {
+			env.MarkSecret(f.name)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Env{}, err
+	}
+	return env, nil
+}
+
+func formatBindValue(v reflect.Value, sep string) (string, error) {
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		xs := make([]string, v.Len())
+		for i := range xs {
+			xs[i] = v.Index(i).String()
+		}
+		return strings.Join(xs, sep), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+}
@@ -0,0 +1,169 @@
+package execx_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedGetters(t *testing.T) {
+	e := execx.EnvFromSlice([]string{
+		"INT=42",
+		"BOOL=true",
+		"DURATION=1m30s",
+		"LIST=a, b ,c",
+		"EMPTY=",
+		"BAD=x",
+	})
+	e.SetWithMode("LAZY_INT", "${INT}0", execx.SetLazy)
+
+	t.Run("int", func(t *testing.T) {
+		got, err := e.GetInt("INT")
+		assert.Nil(t, err)
+		assert.Equal(t, 42, got)
+		got, err = e.GetInt("LAZY_INT")
+		assert.Nil(t, err)
+		assert.Equal(t, 420, got)
+		_, err = e.GetInt("BAD")
+		assert.ErrorContains(t, err, "env BAD")
+		_, err = e.GetInt("UNKNOWN")
+		assert.ErrorIs(t, err, execx.ErrEnvNotFound)
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		got, err := e.GetBool("BOOL")
+		assert.Nil(t, err)
+		assert.True(t, got)
+		_, err = e.GetBool("BAD")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		got, err := e.GetDuration("DURATION")
+		assert.Nil(t, err)
+		assert.Equal(t, 90*time.Second, got)
+		_, err = e.GetDuration("BAD")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		got, err := e.GetList("LIST", ",")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, got)
+		got, err = e.GetList("EMPTY", ",")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, got)
+		_, err = e.GetList("UNKNOWN", ",")
+		assert.ErrorIs(t, err, execx.ErrEnvNotFound)
+	})
+}
+
+type bindConfig struct {
+	Host    string        `env:"HOST"`
+	Port    int           `env:"PORT,required"`
+	Debug   bool          `env:"DEBUG" default:"true"`
+	Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+	Tags    []string      `env:"TAGS" sep:":"`
+	Ratio   float64       `env:"RATIO"`
+	Retry   uint8         `env:"RETRY"`
+	Addr    netip.Addr    `env:"ADDR"`
+	Token   string        `env:"TOKEN,secret"`
+	Ignored string
+	Skipped string `env:"-"`
+}
+
+func TestBind(t *testing.T) {
+	t.Run("bind", func(t *testing.T) {
+		e := execx.EnvFromSlice([]string{
+			"HOST=localhost",
+			"PORT=8080",
+			"TAGS=a:b",
+			"RATIO=0.5",
+			"RETRY=3",
+			"ADDR=127.0.0.1",
+			"TOKEN=t",
+			"Ignored=x",
+		})
+		got := bindConfig{
+			Host: "default host",
+		}
+		assert.Nil(t, e.Bind(&got))
+		assert.Equal(t, bindConfig{
+			Host:    "localhost",
+			Port:    8080,
+			Debug:   true,
+			Timeout: 5 * time.Second,
+			Tags:    []string{"a", "b"},
+			Ratio:   0.5,
+			Retry:   3,
+			Addr:    netip.MustParseAddr("127.0.0.1"),
+			Token:   "t",
+		}, got)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		e := execx.EnvFromSlice([]string{
+			"DEBUG=maybe",
+			"RETRY=300",
+		})
+		var got bindConfig
+		err := e.Bind(&got)
+		assert.ErrorIs(t, err, execx.ErrEnvNotFound)
+		assert.ErrorContains(t, err, "field Port")
+		assert.ErrorContains(t, err, "field Debug from env DEBUG")
+		assert.ErrorContains(t, err, "field Retry from env RETRY")
+	})
+
+	t.Run("invalid target", func(t *testing.T) {
+		var s bindConfig
+		assert.ErrorIs(t, execx.NewEnv().Bind(s), execx.ErrInvalidBinding)
+		assert.ErrorIs(t, execx.NewEnv().Bind((*bindConfig)(nil)), execx.ErrInvalidBinding)
+		var n int
+		assert.ErrorIs(t, execx.NewEnv().Bind(&n), execx.ErrInvalidBinding)
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		var s struct {
+			Ints []int `env:"INTS"`
+		}
+		assert.ErrorIs(t, execx.EnvFromSlice([]string{"INTS=1"}).Bind(&s), execx.ErrUnsupportedType)
+		_, err := execx.EnvFromStruct(s)
+		assert.ErrorIs(t, err, execx.ErrUnsupportedType)
+	})
+
+	t.Run("from struct", func(t *testing.T) {
+		got, err := execx.EnvFromStruct(&bindConfig{
+			Host:    "h",
+			Port:    80,
+			Timeout: time.Minute,
+			Tags:    []string{"x", "y"},
+			Ratio:   1.5,
+			Addr:    netip.MustParseAddr("::1"),
+			Token:   "$t",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"HOST=h",
+			"PORT=80",
+			"DEBUG=false",
+			"TIMEOUT=1m0s",
+			"TAGS=x:y",
+			"RATIO=1.5",
+			"RETRY=0",
+			"ADDR=::1",
+			"TOKEN=$t",
+		}, got.IntoSlice())
+		assert.True(t, got.IsSecret("TOKEN"))
+
+		var restored bindConfig
+		assert.Nil(t, got.Bind(&restored))
+		assert.Equal(t, "$t", restored.Token)
+		assert.Equal(t, []string{"x", "y"}, restored.Tags)
+
+		_, err = execx.EnvFromStruct(1)
+		assert.ErrorIs(t, err, execx.ErrInvalidBinding)
+	})
+}
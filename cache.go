@@ -0,0 +1,140 @@
+package execx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScriptCache stores script files keyed by the hash of the contents and the interpreters,
+// so that identical scripts reuse a file and changed scripts get a new one.
+//
+// Cached files are not removed by [Script.Close], see [ScriptCache.Prune] and [ScriptCache.Clear].
+type ScriptCache struct {
+	dir string
+	mux sync.Mutex
+}
+
+const scriptCachePrefix = "execx-"
+
+// NewScriptCache creates a new [ScriptCache] storing files in dir.
+// The directory is created if it does not exist.
+func NewScriptCache(dir string) *ScriptCache {
+	return &ScriptCache{
+		dir: dir,
+	}
+}
+
+// Dir returns the directory of the cached files.
+func (c *ScriptCache) Dir() string {
+	return c.dir
+}
+
+func (c *ScriptCache) key(content string, shell []string, opt scriptFileOptions) string {
+	h := sha256.New()
+	for _, x := range shell {
+		h.Write([]byte(x))
+		h.Write([]byte{0})
+	}
+	fmt.Fprintf(h, "%s\x00%o\x00", opt.extension, opt.mode.Perm())
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the cached script file, creates it if it does not exist.
+func (c *ScriptCache) get(content string, shell []string, opt scriptFileOptions) (*scriptFile, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	path := filepath.Join(c.dir, scriptCachePrefix+c.key(content, shell, opt)+opt.extension)
+	if isExecutable(path, opt.mode) {
+		// mark as recently used
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return nil, fmt.Errorf("%w: touch cached script %s", err, path)
+		}
+		return &scriptFile{
+			path:   path,
+			mode:   opt.mode,
+			cached: true,
+		}, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return nil, fmt.Errorf("%w: create script cache dir %s", err, c.dir)
+	}
+	// write a temporary file and rename it for other processes sharing the directory
+	f, err := newScriptFile(content, scriptFileOptions{
+		dir:     c.dir,
+		pattern: ".tmp-*",
+		mode:    opt.mode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: create cached script", err)
+	}
+	if err := os.Rename(f.path, path); err != nil {
+		_ = f.close()
+		return nil, fmt.Errorf("%w: rename cached script %s", err, path)
+	}
+	return &scriptFile{
+		path:   path,
+		mode:   opt.mode,
+		cached: true,
+	}, nil
+}
+
+// Prune removes the cached files not used for maxAge.
+func (c *ScriptCache) Prune(maxAge time.Duration) error {
+	threshold := time.Now().Add(-maxAge)
+	return c.remove(func(info fs.FileInfo) bool {
+		return info.ModTime().Before(threshold)
+	})
+}
+
+// Clear removes all the cached files.
+func (c *ScriptCache) Clear() error {
+	return c.remove(func(fs.FileInfo) bool {
+		return true
+	})
+}
+
+func (c *ScriptCache) remove(f func(fs.FileInfo) bool) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: read script cache dir %s", err, c.dir)
+	}
+
+	var errs []error
+	for _, x := range entries {
+		if !x.Type().IsRegular() || !strings.HasPrefix(x.Name(), scriptCachePrefix) {
+			continue
+		}
+		info, err := x.Info()
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !f(info) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, x.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
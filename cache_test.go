@@ -0,0 +1,112 @@
+package execx_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache := execx.NewScriptCache(dir)
+	assert.Equal(t, dir, cache.Dir())
+
+	run := func(t *testing.T, s *execx.Script) (string, string) {
+		t.Helper()
+		var path string
+		got, err := runScriptStdout(t, s)
+		assert.Nil(t, err)
+		lines := strings.SplitN(got, "\n", 2)
+		path = lines[0]
+		return path, lines[1]
+	}
+	newScript := func(content string, shell string) *execx.Script {
+		s := execx.NewScript(`echo "$0"
+`+content, shell)
+		s.Cache = cache
+		return s
+	}
+	countFiles := func(t *testing.T) int {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		assert.Nil(t, err)
+		return len(entries)
+	}
+
+	p1, out := run(t, newScript("echo 1", "sh"))
+	assert.Equal(t, "1\n", out)
+	assert.Equal(t, dir, filepath.Dir(p1))
+	assert.FileExists(t, p1, "cached file is not removed")
+
+	t.Run("reuse", func(t *testing.T) {
+		p, out := run(t, newScript("echo 1", "sh"))
+		assert.Equal(t, "1\n", out)
+		assert.Equal(t, p1, p)
+		assert.Equal(t, 1, countFiles(t))
+	})
+
+	t.Run("expanded content", func(t *testing.T) {
+		s := newScript("echo $V", "sh")
+		s.Env.Set("V", "1")
+		p, out := run(t, s)
+		assert.Equal(t, "1\n", out)
+		assert.Equal(t, p1, p)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		s := newScript("echo 1", "sh")
+		s.KeepScriptFile = true
+		p, _ := run(t, s)
+		assert.Equal(t, p1, p)
+
+		s.Content = `echo "$0"
+echo 2`
+		p, out := run(t, s)
+		assert.Equal(t, "2\n", out)
+		assert.NotEqual(t, p1, p, "changed content")
+
+		p, _ = run(t, newScript("echo 1", "bash"))
+		assert.NotEqual(t, p1, p, "changed interpreter")
+
+		s = newScript("echo 1", "sh")
+		s.FileMode = 0700
+		p, _ = run(t, s)
+		assert.NotEqual(t, p1, p, "changed mode")
+		assert.Equal(t, 4, countFiles(t))
+	})
+
+	t.Run("recreate removed file", func(t *testing.T) {
+		assert.Nil(t, os.Remove(p1))
+		p, out := run(t, newScript("echo 1", "sh"))
+		assert.Equal(t, "1\n", out)
+		assert.Equal(t, p1, p)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		old := time.Now().Add(-time.Hour)
+		assert.Nil(t, os.Chtimes(p1, old, old))
+		assert.Nil(t, cache.Prune(time.Minute))
+		assert.NoFileExists(t, p1)
+		assert.Equal(t, 3, countFiles(t))
+
+		run(t, newScript("echo 1", "sh"))
+		assert.FileExists(t, p1)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, "other"), nil, 0600))
+		assert.Nil(t, cache.Clear())
+		assert.Equal(t, 1, countFiles(t), "other files are kept")
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		c := execx.NewScriptCache(filepath.Join(t.TempDir(), "missing"))
+		assert.Nil(t, c.Prune(0))
+		assert.Nil(t, c.Clear())
+	})
+}
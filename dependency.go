@@ -0,0 +1,153 @@
+package execx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrVariableCycle = errors.New("VariableCycle")
+)
+
+// CycleError is an error of circular references between variables.
+type CycleError struct {
+	// Cycle is a list of variables, the first and the last are the same, e.g. [A B A].
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrVariableCycle, strings.Join(e.Cycle, " -> "))
+}
+
+func (*CycleError) Unwrap() error {
+	return ErrVariableCycle
+}
+
+// References returns the variables directly referenced in target, in order of appearance.
+func References(target string) []string {
+	var (
+		result []string
+		seen   = map[string]bool{}
+	)
+	collectReferences(target, func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	})
+	return result
+}
+
+func collectReferences(s string, add func(string)) {
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i+1 >= len(s) {
+			return
+		}
+		s = s[i:]
+		if s[1] != '{' {
+			name := scanParamName(s[1:], false)
+			if name != "" {
+				add(name)
+			}
+			s = s[1+len(name):]
+			continue
+		}
+
+		end := closingBrace(s, 2)
+		if end < 0 {
+			s = s[1:]
+			continue
+		}
+		content := s[2:end]
+		s = s[end+1:]
+		if len(content) > 1 && content[0] == '#' {
+			if name := scanParamName(content[1:], true); len(name) == len(content)-1 {
+				add(name)
+				continue
+			}
+		}
+		if name := scanParamName(content, true); name != "" {
+			add(name)
+			// references in default values, patterns and so on
+			collectReferences(content[len(name):], add)
+		}
+	}
+}
+
+// Dependencies returns the variables target depends on, directly or through the values of the variables.
+//
+// The result contains unknown variables.
+func (e Env) Dependencies(target string) []string {
+	var (
+		result []string
+		seen   = map[string]bool{}
+		visit  func(string)
+	)
+	visit = func(s string) {
+		for _, name := range References(s) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			result = append(result, name)
+			if v, ok := e.Get(name); ok {
+				visit(v)
+			}
+		}
+	}
+	visit(target)
+	return result
+}
+
+// CheckCycles returns a [*CycleError] if variables refer to each other circularly.
+func (e Env) CheckCycles() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		state = map[string]int{}
+		stack []string
+		visit func(string) []string
+	)
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		v, _ := e.Get(name)
+		for _, ref := range References(v) {
+			if _, ok := e.Get(ref); !ok {
+				continue
+			}
+			switch state[ref] {
+			case visiting:
+				for i, s := range stack {
+					if s == ref {
+						return append(append([]string{}, stack[i:]...), ref)
+					}
+				}
+			case unvisited:
+				if c := visit(ref); c != nil {
+					return c
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, k := range e.Keys() {
+		if state[k] != unvisited {
+			continue
+		}
+		if c := visit(k); c != nil {
+			return &CycleError{
+				Cycle: c,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package execx_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferences(t *testing.T) {
+	for _, tc := range []struct {
+		target string
+		want   []string
+	}{
+		{target: "plain"},
+		{target: "$A ${B} $A", want: []string{"A", "B"}},
+		{target: "${#A} ${B:-$C} ${D/$E/${F}}", want: []string{"A", "B", "C", "D", "E", "F"}},
+		{target: "$1 ${10} $", want: []string{"1", "10"}},
+		{target: "${A", want: nil},
+	} {
+		t.Run(tc.target, func(t *testing.T) {
+			assert.Equal(t, tc.want, execx.References(tc.target))
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	e := execx.EnvFromSlice([]string{
+		"A=$B and $C",
+		"B=${D:-x}",
+		"C=c",
+		"X=$Y",
+		"Y=$X",
+	})
+	assert.Equal(t, []string{"A", "B", "D", "C"}, e.Dependencies("$A"))
+	assert.Equal(t, []string{"C", "UNKNOWN"}, e.Dependencies("$C $UNKNOWN"))
+	assert.Equal(t, []string{"X", "Y"}, e.Dependencies("$X"))
+	assert.Nil(t, e.Dependencies("plain"))
+}
+
+func TestCycle(t *testing.T) {
+	t.Run("no cycles", func(t *testing.T) {
+		e := execx.EnvFromSlice([]string{"A=$B", "B=$C", "C=c", "D=$A$B"})
+		assert.Nil(t, e.CheckCycles())
+		got, err := e.ExpandStrict("$D")
+		assert.Nil(t, err)
+		assert.Equal(t, "cc", got)
+	})
+
+	for _, tc := range []struct {
+		title  string
+		env    []string
+		target string
+		want   []string
+		// result of lenient expansion
+		expanded string
+	}{
+		{
+			title:    "self",
+			env:      []string{"A=x$A"},
+			target:   "$A",
+			want:     []string{"A", "A"},
+			expanded: "x$A",
+		},
+		{
+			title:    "mutual",
+			env:      []string{"A=$B", "B=$A"},
+			target:   "value is $A",
+			want:     []string{"A", "B", "A"},
+			expanded: "value is $A",
+		},
+		{
+			title:    "indirect",
+			env:      []string{"X=$A", "A=B", "B=C", "C=A"},
+			target:   "$X",
+			want:     nil,
+			expanded: "B",
+		},
+		{
+			title:  "triangle",
+			env:    []string{"X=$A", "A=$B", "B=$C", "C=${A:-d}"},
+			target: "$X",
+			want:   []string{"A", "B", "C", "A"},
+			// the circular reference is left
+			expanded: "${A:-d}",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			e := execx.NewEnv()
+			for _, kv := range tc.env {
+				// set without expansion
+				e.Merge(execx.EnvFromSlice([]string{kv}))
+			}
+			assert.Equal(t, tc.expanded, e.Expand(tc.target))
+
+			err := e.CheckCycles()
+			if tc.want == nil {
+				assert.Nil(t, err)
+				return
+			}
+			var cerr *execx.CycleError
+			if assert.ErrorAs(t, err, &cerr) {
+				assert.Equal(t, tc.want, cerr.Cycle)
+			}
+			assert.ErrorIs(t, err, execx.ErrVariableCycle)
+
+			_, err = e.ExpandStrict(tc.target)
+			assert.ErrorIs(t, err, execx.ErrVariableCycle)
+			t.Logf("err=%v", err)
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package execx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidDotenv = errors.New("InvalidDotenv")
+)
+
+// EnvFromDotenv creates a new [Env] from dotenv format.
+//
+// See [Env.ReadDotenv] for the format.
+func EnvFromDotenv(r io.Reader) (Env, error) {
+	env := NewEnv()
+	if err := env.ReadDotenv(r); err != nil {
+		return Env{}, err
+	}
+	return env, nil
+}
+
+// LoadDotenv creates a new [Env] from a dotenv file.
+func LoadDotenv(path string) (Env, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Env{}, fmt.Errorf("%w: open dotenv %s", err, path)
+	}
+	defer f.Close()
+	env, err := EnvFromDotenv(f)
+	if err != nil {
+		return Env{}, fmt.Errorf("%w: load dotenv %s", err, path)
+	}
+	return env, nil
+}
+
+// ReadDotenv reads variables in dotenv format and sets them.
+//
+//	# comment
+//	KEY1=value # comment
+//	export KEY2=value
+//	KEY3='raw $value'
+//	KEY4="expanded ${KEY1}\nwith escapes"
+//	KEY5="multi
+//	line"
+//
+// Unquoted and double-quoted values are expanded by [Env.Expand] against the variables already set,
+// including the preceding lines, and the default, assignment, alternative and length forms apply to the unset variables.
+// Double-quoted values support escapes: \n, \r, \t, \", \\ and \$.
+// Single-quoted values are taken literally.
+func (e Env) ReadDotenv(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w: read dotenv", err)
+	}
+	p := &dotenvParser{
+		src:  string(b),
+		line: 1,
+		env:  e,
+	}
+	return p.parse()
+}
+
+var (
+	dotenvKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*`)
+)
+
+type dotenvParser struct {
+	src  string
+	pos  int
+	line int
+	env  Env
+}
+
+func (p *dotenvParser) errorf(format string, a ...any) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidDotenv, p.line, fmt.Sprintf(format, a...))
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *dotenvParser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *dotenvParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *dotenvParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+}
+
+// skipLine skips the rest of the line, requires only spaces or a comment.
+func (p *dotenvParser) skipLine() error {
+	p.skipSpaces()
+	if p.eof() {
+		return nil
+	}
+	switch p.peek() {
+	case '\n':
+		p.next()
+		return nil
+	case '\r':
+		p.next()
+		if !p.eof() && p.peek() == '\n' {
+			p.next()
+		}
+		return nil
+	case '#':
+		for !p.eof() && p.next() != '\n' {
+		}
+		return nil
+	default:
+		return p.errorf("unexpected character %q", p.peek())
+	}
+}
+
+func (p *dotenvParser) parse() error {
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			return nil
+		}
+		if c := p.peek(); c == '#' || c == '\n' || c == '\r' {
+			if err := p.skipLine(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.parseEntry(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *dotenvParser) parseEntry() error {
+	if rest := p.src[p.pos:]; strings.HasPrefix(rest, "export ") || strings.HasPrefix(rest, "export\t") {
+		p.pos += len("export")
+		p.skipSpaces()
+	}
+
+	key := dotenvKeyRegex.FindString(p.src[p.pos:])
+	if key == "" {
+		return p.errorf("invalid key")
+	}
+	p.pos += len(key)
+	p.skipSpaces()
+	if p.eof() || p.next() != '=' {
+		return p.errorf("missing = after %s", key)
+	}
+	p.skipSpaces()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return fmt.Errorf("%w: key %s", err, key)
+	}
+	p.env.set(key, value)
+	return p.skipLine()
+}
+
+func (p *dotenvParser) parseValue() (string, error) {
+	if p.eof() {
+		return "", nil
+	}
+	switch p.peek() {
+	case '\'':
+		return p.parseSingleQuoted()
+	case '"':
+		return p.parseDoubleQuoted()
+	default:
+		return p.parseUnquoted(), nil
+	}
+}
+
+func (p *dotenvParser) parseSingleQuoted() (string, error) {
+	startLine := p.line
+	p.next()
+	i := strings.IndexByte(p.src[p.pos:], '\'')
+	if i < 0 {
+		p.line = startLine
+		return "", p.errorf("unterminated single quote")
+	}
+	value := p.src[p.pos : p.pos+i]
+	p.line += strings.Count(value, "\n")
+	p.pos += i + 1
+	return value, nil
+}
+
+func (p *dotenvParser) parseDoubleQuoted() (string, error) {
+	var (
+		startLine = p.line
+		b         strings.Builder
+		plain     strings.Builder
+		flush     = func() {
+			b.WriteString(p.env.expandValue(plain.String()))
+			plain.Reset()
+		}
+	)
+	p.next()
+	for !p.eof() {
+		c := p.next()
+		switch c {
+		case '"':
+			flush()
+			return b.String(), nil
+		case '\\':
+			if p.eof() {
+				continue
+			}
+			var escaped string
+			switch x := p.next(); x {
+			case 'n':
+				escaped = "\n"
+			case 'r':
+				escaped = "\r"
+			case 't':
+				escaped = "\t"
+			case '"', '\\', '$':
+				escaped = string(x)
+			default:
+				escaped = string([]byte{'\\', x})
+			}
+			flush()
+			b.WriteString(escaped)
+		default:
+			plain.WriteByte(c)
+		}
+	}
+	p.line = startLine
+	return "", p.errorf("unterminated double quote")
+}
+
+func (p *dotenvParser) parseUnquoted() string {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == '\n' || c == '\r' {
+			break
+		}
+		if c == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+		p.next()
+	}
+	return p.env.expandValue(strings.TrimRight(p.src[start:p.pos], " \t"))
+}
+
+var (
+	dotenvBareValueRegex = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+// WriteDotenv writes the variables in dotenv format, in order.
+//
+// Values are quoted if needed, so that [Env.ReadDotenv] restores them as they are.
+func (e Env) WriteDotenv(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for k, v := range e.All() {
+		if _, err := fmt.Fprintf(bw, "%s=%s\n", k, quoteDotenvValue(v)); err != nil {
+			return fmt.Errorf("%w: write dotenv %s", err, k)
+		}
+	}
+	return bw.Flush()
+}
+
+func quoteDotenvValue(v string) string {
+	switch {
+	case dotenvBareValueRegex.MatchString(v):
+		return v
+	case !strings.Contains(v, "'"):
+		return "'" + v + "'"
+	default:
+		r := strings.NewReplacer(
+			`\`, `\\`,
+			`"`, `\"`,
+			`$`, `\$`,
+			"\n", `\n`,
+			"\r", `\r`,
+			"\t", `\t`,
+		)
+		return `"` + r.Replace(v) + `"`
+	}
+}
@@ -0,0 +1,182 @@
+package execx_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDotenv(t *testing.T) {
+	t.Run("read", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			input string
+			want  []string
+			err   bool
+		}{
+			{
+				title: "empty",
+				input: "",
+				want:  []string{},
+			},
+			{
+				title: "comments and blank lines",
+				input: `# comment
+
+  # indented comment
+A=a
+`,
+				want: []string{"A=a"},
+			},
+			{
+				title: "unquoted",
+				input: `A=value
+B = spaced value  
+C=value # comment
+D=value#not comment
+E=
+`,
+				want: []string{"A=value", "B=spaced value", "C=value", "D=value#not comment", "E="},
+			},
+			{
+				title: "export",
+				input: `export A=a
+export	B=b
+exported=c`,
+				want: []string{"A=a", "B=b", "exported=c"},
+			},
+			{
+				title: "single quoted",
+				input: `A='raw $HOME \n "quoted"'
+B='multi
+line' # comment`,
+				want: []string{`A=raw $HOME \n "quoted"`, "B=multi\nline"},
+			},
+			{
+				title: "double quoted",
+				input: `A="tab\tnewline\nquote\"backslash\\dollar\$A"
+B="multi
+line"
+C="unknown\q"`,
+				want: []string{"A=tab\tnewline\nquote\"backslash\\dollar$A", "B=multi\nline", `C=unknown\q`},
+			},
+			{
+				title: "expand",
+				input: `A=a
+B=${A}b
+C="$B c"
+D='$C'
+E=$UNKNOWN`,
+				want: []string{"A=a", "B=ab", "C=ab c", "D=$C", "E=$UNKNOWN"},
+			},
+			{
+				title: "expand unset",
+				input: `A=${UNKNOWN:-default}
+B="[${UNKNOWN:+alt}] ${#UNKNOWN}"
+C=${A:-x}`,
+				want: []string{"A=default", "B=[] 0", "C=default"},
+			},
+			{
+				title: "crlf",
+				input: "A=a\r\nB='b'\r\n",
+				want:  []string{"A=a", "B=b"},
+			},
+			{
+				title: "invalid key",
+				input: "1A=a",
+				err:   true,
+			},
+			{
+				title: "missing equal",
+				input: "A a",
+				err:   true,
+			},
+			{
+				title: "unterminated single quote",
+				input: "A='a",
+				err:   true,
+			},
+			{
+				title: "unterminated double quote",
+				input: `A="a`,
+				err:   true,
+			},
+			{
+				title: "trailing garbage",
+				input: `A="a" b`,
+				err:   true,
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				got, err := execx.EnvFromDotenv(bytes.NewBufferString(tc.input))
+				if tc.err {
+					t.Logf("err=%v", err)
+					assert.ErrorIs(t, err, execx.ErrInvalidDotenv)
+					return
+				}
+				if !assert.Nil(t, err) {
+					return
+				}
+				assert.Equal(t, tc.want, got.IntoSlice())
+			})
+		}
+	})
+
+	t.Run("read into existing env", func(t *testing.T) {
+		e := execx.EnvFromSlice([]string{"PATH=/bin"})
+		assert.Nil(t, e.ReadDotenv(bytes.NewBufferString("PATH=$PATH:/usr/local/bin\nA=$PATH")))
+		assert.Equal(t, []string{"PATH=/bin:/usr/local/bin", "A=/bin:/usr/local/bin"}, e.IntoSlice())
+	})
+
+	t.Run("error line", func(t *testing.T) {
+		_, err := execx.EnvFromDotenv(bytes.NewBufferString("A='a\nb'\n\nB=\"b"))
+		assert.ErrorContains(t, err, "line 4")
+	})
+
+	t.Run("load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".env")
+		assert.Nil(t, os.WriteFile(path, []byte("A=a\n"), 0600))
+		got, err := execx.LoadDotenv(path)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"A=a"}, got.IntoSlice())
+
+		_, err = execx.LoadDotenv(filepath.Join(t.TempDir(), "missing"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("write", func(t *testing.T) {
+		e := execx.NewEnv()
+		for _, kv := range [][2]string{
+			{"BARE", "/usr/bin:/bin"},
+			{"EMPTY", ""},
+			{"SPACE", "a b"},
+			{"DOLLAR", "$HOME"},
+			{"NEWLINE", "a\nb"},
+			{"SINGLE", `it's "$x"`},
+			{"MIXED", "it's\t\\\n"},
+		} {
+			e.Set(kv[0], kv[1])
+		}
+
+		var b strings.Builder
+		assert.Nil(t, e.WriteDotenv(&b))
+		assert.Equal(t, `BARE=/usr/bin:/bin
+EMPTY=
+SPACE='a b'
+DOLLAR='$HOME'
+NEWLINE='a
+b'
+SINGLE="it's \"\$x\""
+MIXED="it's\t\\\n"
+`, b.String())
+
+		got, err := execx.EnvFromDotenv(bytes.NewBufferString(b.String()))
+		assert.Nil(t, err)
+		assert.Equal(t, e, got, "round trip")
+	})
+}
@@ -2,13 +2,60 @@ package execx
 
 import (
 	"fmt"
+	"iter"
+	"maps"
 	"os"
-	"regexp"
+	"path"
+	"slices"
 	"strings"
 )
 
-// Env represents a set of environment variables.
-type Env map[string]string
+// Env represents an ordered set of environment variables.
+//
+// Variables are kept in insertion order, and setting an existing variable keeps its position.
+// Like a map, copies of an [Env] share the same variables.
+type Env struct {
+	vars *envVars
+}
+
+type envVars struct {
+	keys    []string
+	values  map[string]string
+	secrets map[string]bool
+	modes   map[string]SetMode
+}
+
+// SetMode controls the expansion of a value when setting a variable.
+type SetMode int
+
+const (
+	// SetAuto expands the value only if the variable already exists,
+	// e.g. PATH=$PATH:/usr/local/bin appends to the current PATH.
+	SetAuto SetMode = iota
+	// SetRaw sets the value as it is.
+	SetRaw
+	// SetExpand expands the value against the current variables.
+	SetExpand
+	// SetLazy sets the value as it is, and expands it when the variables are passed to commands,
+	// see [Env.IntoSlice].
+	// Note that the value cannot refer to the previous value of the variable itself.
+	SetLazy
+)
+
+func (m SetMode) String() string {
+	switch m {
+	case SetAuto:
+		return "auto"
+	case SetRaw:
+		return "raw"
+	case SetExpand:
+		return "expand"
+	case SetLazy:
+		return "lazy"
+	default:
+		return fmt.Sprintf("SetMode(%d)", int(m))
+	}
+}
 
 // EnvFromEnviron creates a new [Env] from [os.Environ].
 func EnvFromEnviron() Env {
@@ -17,7 +64,33 @@ func EnvFromEnviron() Env {
 
 // NewEnv creates a new empty [Env].
 func NewEnv() Env {
-	return Env(make(map[string]string))
+	return Env{
+		vars: &envVars{
+			values:  map[string]string{},
+			secrets: map[string]bool{},
+			modes:   map[string]SetMode{},
+		},
+	}
+}
+
+// EnvFromMap creates a new [Env] from a map, the variables are set in order of their names.
+func EnvFromMap(m map[string]string) Env {
+	env := NewEnv()
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		env.Set(k, m[k])
+	}
+	return env
+}
+
+// EnvFromPairs creates a new [Env] from alternating keys and values, in order.
+//
+// A trailing key without a value is ignored.
+func EnvFromPairs(kv ...string) Env {
+	env := NewEnv()
+	for i := 0; i+1 < len(kv); i += 2 {
+		env.Set(kv[i], kv[i+1])
+	}
+	return env
 }
 
 // EnvFromSlice creates a new [Env] from strings, in the form "key=value".
@@ -33,97 +106,186 @@ func EnvFromSlice(envSlice []string) Env {
 	return env
 }
 
+// Get returns the value of the variable.
 func (e Env) Get(key string) (string, bool) {
-	v, ok := e[key]
+	if e.vars == nil {
+		return "", false
+	}
+	v, ok := e.vars.values[key]
 	return v, ok
 }
 
+// Set sets the variable by [SetAuto].
 func (e Env) Set(key, value string) {
-	if _, ok := e[key]; ok {
+	e.SetWithMode(key, value, SetAuto)
+}
+
+// SetWithMode sets the variable, the mode controls the expansion of the value.
+//
+// The mode is recorded and [Env.Merge] honors it.
+func (e Env) SetWithMode(key, value string, mode SetMode) {
+	_, exists := e.Get(key)
+	switch mode {
+	case SetAuto:
+		if exists {
+			value = e.Expand(value)
+		}
+	case SetExpand:
 		value = e.Expand(value)
 	}
-	e[key] = value
+	e.set(key, value)
+	if mode == SetAuto {
+		delete(e.vars.modes, key)
+	} else {
+		e.vars.modes[key] = mode
+	}
 }
 
-func (e Env) Merge(other Env) {
-	for k, v := range other {
-		e.Set(k, v)
+// Mode returns the mode used when the variable was set.
+func (e Env) Mode(key string) SetMode {
+	if e.vars == nil {
+		return SetAuto
 	}
+	return e.vars.modes[key]
 }
 
-// IntoSlice converts into os.Environ format.
-func (e Env) IntoSlice() []string {
-	var (
-		i      int
-		result = make([]string, len(e))
-	)
-	for k, v := range e {
-		result[i] = fmt.Sprintf("%s=%s", k, v)
-		i++
+func (e Env) set(key, value string) {
+	if _, ok := e.vars.values[key]; !ok {
+		e.vars.keys = append(e.vars.keys, key)
 	}
-	return result
+	e.vars.values[key] = value
 }
 
-const expandMaxAttempts = 10
+// Unset removes the variable.
+func (e Env) Unset(key string) {
+	if _, ok := e.Get(key); !ok {
+		return
+	}
+	delete(e.vars.values, key)
+	delete(e.vars.secrets, key)
+	delete(e.vars.modes, key)
+	e.vars.keys = slices.DeleteFunc(e.vars.keys, func(k string) bool {
+		return k == key
+	})
+}
 
-// Expand expands environment variables in target.
-func (e Env) Expand(target string) string {
-	var (
-		originalTarget = target
-		result         string
-		count          int
-		missingKeys    = map[string]bool{}
-	)
+// Merge sets the variables of other by the modes used when they were set.
+func (e Env) Merge(other Env) {
+	for k, v := range other.All() {
+		e.SetWithMode(k, v, other.Mode(k))
+		if other.IsSecret(k) {
+			e.vars.secrets[k] = true
+		}
+	}
+}
 
-	get := func(key string) string {
-		if value, found := e[key]; found {
-			return value
+// MergeWithMode sets the variables of other by the mode.
+func (e Env) MergeWithMode(other Env, mode SetMode) {
+	for k, v := range other.All() {
+		e.SetWithMode(k, v, mode)
+		if other.IsSecret(k) {
+			e.vars.secrets[k] = true
 		}
-		missingKeys[key] = true
-		return fmt.Sprintf("${%s}", key)
 	}
+}
 
-	for result = os.Expand(target, get); result != target && count < expandMaxAttempts; count++ {
-		target = result
-		result = os.Expand(result, get)
+// Len returns the number of the variables.
+func (e Env) Len() int {
+	if e.vars == nil {
+		return 0
 	}
+	return len(e.vars.keys)
+}
 
-	missingKey2Replaces := map[string][]bool{}
-	for key := range missingKeys {
-		// match with $var or ${var}
-		re, err := regexp.Compile(fmt.Sprintf(`\$(%[1]s\b|\{%[1]s\})`, key))
-		if err != nil {
-			continue
-		}
-		found := re.FindAllString(originalTarget, -1)
-		replaces := make([]bool, len(found))
-		for i, x := range found {
-			// true if original variable is raw (e.g. $var)
-			replaces[i] = !strings.Contains(x, "{")
+// Keys returns the names of the variables in order.
+func (e Env) Keys() []string {
+	if e.vars == nil {
+		return nil
+	}
+	return slices.Clone(e.vars.keys)
+}
+
+// All returns an iterator over the variables in order.
+func (e Env) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, k := range e.Keys() {
+			if !yield(k, e.vars.values[k]) {
+				return
+			}
 		}
-		missingKey2Replaces[key] = replaces
 	}
+}
 
-	// revert missing variables to original format
-	countMap := map[string]int{}
-	replaceMissing := func(key string) string {
-		index := countMap[key]
-		countMap[key]++
-		if replaces, found := missingKey2Replaces[key]; found {
-			if index < len(replaces) && replaces[index] {
-				return fmt.Sprintf("$%s", key)
+// Filter returns a new [Env] that contains the variables for which f returns true.
+func (e Env) Filter(f func(key string) bool) Env {
+	result := NewEnv()
+	for k, v := range e.All() {
+		if f(k) {
+			result.set(k, v)
+			if e.IsSecret(k) {
+				result.vars.secrets[k] = true
 			}
+			if m := e.Mode(k); m != SetAuto {
+				result.vars.modes[k] = m
+			}
+		}
+	}
+	return result
+}
+
+// Clone returns a new [Env] that does not share the variables with e.
+func (e Env) Clone() Env {
+	return e.Filter(func(string) bool {
+		return true
+	})
+}
+
+// Allow returns a new [Env] that contains only the variables whose names match any of the patterns.
+//
+// A pattern is a variable name or a glob, see [path.Match].
+func (e Env) Allow(pattern ...string) Env {
+	return e.Filter(func(key string) bool {
+		return matchAnyName(key, pattern)
+	})
+}
+
+// Deny returns a new [Env] that excludes the variables whose names match any of the patterns.
+//
+// A pattern is a variable name or a glob, see [path.Match].
+func (e Env) Deny(pattern ...string) Env {
+	return e.Filter(func(key string) bool {
+		return !matchAnyName(key, pattern)
+	})
+}
+
+func matchAnyName(name string, pattern []string) bool {
+	for _, p := range pattern {
+		if ok, err := path.Match(p, name); err == nil && ok {
+			return true
 		}
-		return fmt.Sprintf("${%s}", key)
 	}
-	return os.Expand(result, replaceMissing)
+	return false
 }
 
-// ExpandStrings expands environment variables in multiple targets.
-func (e Env) ExpandStrings(target []string) []string {
-	result := make([]string, len(target))
-	for i, t := range target {
-		result[i] = e.Expand(t)
+// IntoMap converts into a map, the values are the same as [Env.All].
+func (e Env) IntoMap() map[string]string {
+	result := make(map[string]string, e.Len())
+	for k, v := range e.All() {
+		result[k] = v
+	}
+	return result
+}
+
+// IntoSlice converts into os.Environ format, in order.
+//
+// The values of the variables set by [SetLazy] are expanded.
+func (e Env) IntoSlice() []string {
+	result := make([]string, 0, e.Len())
+	for k, v := range e.All() {
+		if e.Mode(k) == SetLazy {
+			v = e.Expand(v)
+		}
+		result = append(result, fmt.Sprintf("%s=%s", k, v))
 	}
 	return result
 }
@@ -1,6 +1,8 @@
 package execx_test
 
 import (
+	"bytes"
+	"context"
 	"sort"
 	"testing"
 
@@ -23,6 +25,94 @@ func TestEnv(t *testing.T) {
 		}
 	})
 
+	t.Run("order", func(t *testing.T) {
+		e := execx.EnvFromSlice([]string{"B=1", "A=2", "C=3"})
+		e.Set("D", "4")
+		e.Set("A", "5")
+		e.Unset("B")
+		assert.Equal(t, []string{"A", "C", "D"}, e.Keys())
+		assert.Equal(t, []string{"A=5", "C=3", "D=4"}, e.IntoSlice())
+		assert.Equal(t, 3, e.Len())
+
+		var got []string
+		for k, v := range e.All() {
+			got = append(got, k+v)
+		}
+		assert.Equal(t, []string{"A5", "C3", "D4"}, got)
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		var e execx.Env
+		_, ok := e.Get("KEY")
+		assert.False(t, ok)
+		assert.Equal(t, 0, e.Len())
+		assert.Equal(t, 0, len(e.IntoSlice()))
+		assert.Equal(t, "$KEY", e.Expand("$KEY"))
+	})
+
+	t.Run("share", func(t *testing.T) {
+		e := execx.NewEnv()
+		c := e
+		c.Set("KEY", "VAL")
+		got, ok := e.Get("KEY")
+		assert.True(t, ok)
+		assert.Equal(t, "VAL", got)
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		e := execx.EnvFromPairs("KEY", "VAL")
+		e.SetSecret("TOKEN", "t")
+		c := e.Clone()
+		c.Set("KEY", "VAL2")
+		got, _ := e.Get("KEY")
+		assert.Equal(t, "VAL", got)
+		assert.True(t, c.IsSecret("TOKEN"))
+	})
+
+	t.Run("pairs", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			kv    []string
+			want  []string
+		}{
+			{
+				title: "empty",
+				want:  []string{},
+			},
+			{
+				title: "in order",
+				kv:    []string{"B", "1", "A", "2"},
+				want:  []string{"B=1", "A=2"},
+			},
+			{
+				title: "value with equal",
+				kv:    []string{"A", "x=y"},
+				want:  []string{"A=x=y"},
+			},
+			{
+				title: "trailing key",
+				kv:    []string{"A", "1", "B"},
+				want:  []string{"A=1"},
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				assert.Equal(t, tc.want, execx.EnvFromPairs(tc.kv...).IntoSlice())
+			})
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		m := map[string]string{
+			"C": "3",
+			"A": "1",
+			"B": "$A",
+		}
+		e := execx.EnvFromMap(m)
+		assert.Equal(t, []string{"A", "B", "C"}, e.Keys())
+		assert.Equal(t, m, e.IntoMap())
+		assert.Equal(t, map[string]string{}, execx.Env{}.IntoMap())
+	})
+
 	t.Run("set", func(t *testing.T) {
 		e := execx.NewEnv()
 		{
@@ -38,6 +128,55 @@ func TestEnv(t *testing.T) {
 		assert.Equal(t, execx.EnvFromSlice([]string{"KEY=VAL"}), e)
 	})
 
+	t.Run("unset", func(t *testing.T) {
+		e := execx.EnvFromSlice([]string{"KEY=VAL", "KEY2=VAL2"})
+		e.Unset("KEY")
+		e.Unset("UNKNOWN")
+		_, ok := e.Get("KEY")
+		assert.False(t, ok)
+		assert.Equal(t, execx.EnvFromSlice([]string{"KEY2=VAL2"}), e)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		e := execx.EnvFromSlice([]string{"HOME=/home/u", "LC_ALL=C", "LC_CTYPE=C", "TOKEN=secret"})
+		for _, tc := range []struct {
+			title string
+			got   execx.Env
+			want  execx.Env
+		}{
+			{
+				title: "allow nothing",
+				got:   e.Allow(),
+				want:  execx.NewEnv(),
+			},
+			{
+				title: "allow names and globs",
+				got:   e.Allow("HOME", "LC_*", "UNKNOWN"),
+				want:  execx.EnvFromSlice([]string{"HOME=/home/u", "LC_ALL=C", "LC_CTYPE=C"}),
+			},
+			{
+				title: "deny nothing",
+				got:   e.Deny(),
+				want:  e,
+			},
+			{
+				title: "deny names and globs",
+				got:   e.Deny("TOKEN", "LC_*"),
+				want:  execx.EnvFromSlice([]string{"HOME=/home/u"}),
+			},
+			{
+				title: "invalid glob",
+				got:   e.Allow("["),
+				want:  execx.NewEnv(),
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				assert.Equal(t, tc.want, tc.got)
+			})
+		}
+		assert.Equal(t, 4, len(e.IntoSlice()), "original is not changed")
+	})
+
 	t.Run("merge", func(t *testing.T) {
 		e := execx.NewEnv()
 		e.Merge(execx.NewEnv())
@@ -89,6 +228,118 @@ func TestEnv(t *testing.T) {
 		}))
 	})
 
+	t.Run("set mode", func(t *testing.T) {
+		for _, tc := range []struct {
+			title  string
+			mode   execx.SetMode
+			exists bool
+			// stored value
+			want string
+			// value passed to commands
+			wantSlice string
+		}{
+			{
+				title:     "auto new",
+				mode:      execx.SetAuto,
+				want:      "$B:x",
+				wantSlice: "$B:x",
+			},
+			{
+				title:     "auto existing",
+				mode:      execx.SetAuto,
+				exists:    true,
+				want:      "b:x",
+				wantSlice: "b:x",
+			},
+			{
+				title:     "raw new",
+				mode:      execx.SetRaw,
+				want:      "$B:x",
+				wantSlice: "$B:x",
+			},
+			{
+				title:     "raw existing",
+				mode:      execx.SetRaw,
+				exists:    true,
+				want:      "$B:x",
+				wantSlice: "$B:x",
+			},
+			{
+				title:     "expand new",
+				mode:      execx.SetExpand,
+				want:      "b:x",
+				wantSlice: "b:x",
+			},
+			{
+				title:     "expand existing",
+				mode:      execx.SetExpand,
+				exists:    true,
+				want:      "b:x",
+				wantSlice: "b:x",
+			},
+			{
+				title:     "lazy new",
+				mode:      execx.SetLazy,
+				want:      "$B:x",
+				wantSlice: "c:x",
+			},
+			{
+				title:     "lazy existing",
+				mode:      execx.SetLazy,
+				exists:    true,
+				want:      "$B:x",
+				wantSlice: "c:x",
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				e := execx.NewEnv()
+				if tc.exists {
+					e.Set("A", "a")
+				}
+				e.Set("B", "b")
+				e.SetWithMode("A", "$B:x", tc.mode)
+				e.Set("B", "c")
+
+				got, _ := e.Get("A")
+				assert.Equal(t, tc.want, got)
+				assert.Equal(t, tc.mode, e.Mode("A"))
+				assert.Contains(t, e.IntoSlice(), "A="+tc.wantSlice)
+			})
+		}
+	})
+
+	t.Run("merge honors mode", func(t *testing.T) {
+		other := execx.NewEnv()
+		other.SetWithMode("RAW", "$X-raw", execx.SetRaw)
+		other.SetWithMode("LAZY", "$X-lazy", execx.SetLazy)
+		other.Set("AUTO", "$AUTO-auto")
+
+		e := execx.EnvFromSlice([]string{"RAW=r", "LAZY=l", "AUTO=a"})
+		e.Merge(other)
+		e.Set("X", "x")
+		assert.Equal(t, []string{"RAW=$X-raw", "LAZY=x-lazy", "AUTO=a-auto", "X=x"}, e.IntoSlice())
+		assert.Equal(t, execx.SetRaw, e.Mode("RAW"))
+		assert.Equal(t, execx.SetLazy, e.Mode("LAZY"))
+		assert.Equal(t, execx.SetAuto, e.Mode("AUTO"))
+
+		t.Run("with mode", func(t *testing.T) {
+			e := execx.EnvFromSlice([]string{"X=x", "AUTO=a"})
+			e.MergeWithMode(other, execx.SetExpand)
+			assert.Equal(t, []string{"X=x", "AUTO=a-auto", "RAW=x-raw", "LAZY=x-lazy"}, e.IntoSlice())
+			assert.Equal(t, execx.SetExpand, e.Mode("LAZY"))
+		})
+	})
+
+	t.Run("lazy at command time", func(t *testing.T) {
+		t.Setenv("test_env_lazy_home", "/home/u")
+		cmd := execx.New("sh", "-c", `echo "$CONFIG"`)
+		cmd.Env.SetWithMode("CONFIG", "${test_env_lazy_home}/.config", execx.SetLazy)
+		cmd.Inherit = execx.InheritFull
+		r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+		assert.Nil(t, err)
+		assertReader(t, bytes.NewBufferString("/home/u/.config\n"), r.Stdout)
+	})
+
 	t.Run("append", func(t *testing.T) {
 		e := execx.NewEnv()
 		e.Set("A", "a")
@@ -13,18 +13,41 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-//go:generate go tool goconfig -field "StdoutConsumer func(Token)|StderrConsumer func(Token)|Delim byte|CaptureStdout bool|CaptureStderr bool" -option -output exec_config_generated.go -configOption Option
+//go:generate go tool goconfig -field "StdoutConsumer func(Token)|StderrConsumer func(Token)|Delim byte|CaptureStdout bool|CaptureStderr bool|TokenTransform TokenTransform" -option -output exec_config_generated.go -configOption Option
 
 // Cmd is an external command.
 type Cmd struct {
+	// Args are expanded by [Env.Expand] when the command runs,
+	// and the default, assignment, alternative and length forms apply to the variables not in the Env.
 	Args   []string
 	Stdin  io.Reader
 	Stdout io.Writer
 	Stderr io.Writer
 	Dir    string
 	Env    Env
+	// Inherit controls the environment variables inherited from the current process.
+	Inherit InheritMode
+	// InheritAllow is a list of names or globs of the inherited variables when Inherit is [InheritAllowList].
+	InheritAllow []string
+
+	// indexes of Args not to be expanded
+	rawArgs []int
+	// applied to stderr tokens before the transform of the options
+	stderrTransform TokenTransform
 }
 
+// InheritMode controls the environment variables inherited from the current process.
+type InheritMode int
+
+const (
+	// InheritNone passes only [Cmd.Env] to the command.
+	InheritNone InheritMode = iota
+	// InheritFull passes [os.Environ] overridden by [Cmd.Env] to the command.
+	InheritFull
+	// InheritAllowList passes [os.Environ] filtered by [Cmd.InheritAllow], overridden by [Cmd.Env], to the command.
+	InheritAllowList
+)
+
 // Result is [Cmd] execution result.
 type Result struct {
 	// ExpandedArgs is actual command.
@@ -33,6 +56,25 @@ type Result struct {
 	Stdout io.Reader
 	// If [WithCaptureStderr] is true, records stderr.
 	Stderr io.Reader
+
+	redactor *redactor
+}
+
+// RedactedArgs returns ExpandedArgs with the values of the secret variables redacted.
+func (r Result) RedactedArgs() []string {
+	result := make([]string, len(r.ExpandedArgs))
+	for i, x := range r.ExpandedArgs {
+		if r.redactor != nil {
+			x = r.redactor.redact(x)
+		}
+		result[i] = x
+	}
+	return result
+}
+
+// String returns the actual command quoted by [QuoteArgs], the values of the secret variables are redacted.
+func (r Result) String() string {
+	return QuoteArgs(r.RedactedArgs()...)
 }
 
 type SplitFunc = bufio.SplitFunc
@@ -58,7 +100,7 @@ func (t token) Bytes() []byte {
 
 // Create a new [Cmd].
 //
-// Set the current directory to [Cmd.Dir], current environment variables to [Cmd.Env].
+// Set the current directory to [Cmd.Dir], an empty [Env] to [Cmd.Env] and [InheritNone] to [Cmd.Inherit].
 func New(name string, arg ...string) *Cmd {
 	return &Cmd{
 		Args: append([]string{name}, arg...),
@@ -74,16 +116,41 @@ func (c Cmd) IntoExecCmd(ctx context.Context) *exec.Cmd {
 	return cmd
 }
 
+// Environ returns the environment variables passed to the command.
+func (c Cmd) Environ() Env {
+	var env Env
+	switch c.Inherit {
+	case InheritFull:
+		env = EnvFromEnviron()
+	case InheritAllowList:
+		env = EnvFromEnviron().Allow(c.InheritAllow...)
+	default:
+		env = NewEnv()
+	}
+	env.Merge(c.Env)
+	return env
+}
+
 func (c Cmd) prepare(ctx context.Context) (*exec.Cmd, *Result) {
-	args := c.Env.ExpandStrings(c.Args)
+	env := c.Environ()
+	args := make([]string, len(c.Args))
+	for i, x := range c.Args {
+		args[i] = env.expandValue(x)
+	}
+	for _, i := range c.rawArgs {
+		if i < len(args) {
+			args[i] = c.Args[i]
+		}
+	}
 
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	cmd.Stdin = c.Stdin
 	cmd.Dir = c.Dir
-	cmd.Env = c.Env.IntoSlice()
+	cmd.Env = env.IntoSlice()
 
 	result := &Result{
 		ExpandedArgs: args,
+		redactor:     newRedactor(env.SecretValues()),
 	}
 	return cmd, result
 }
@@ -117,8 +184,10 @@ func (c Cmd) prepareWriters(result *Result, cfg *Config) *cmdWriters {
 //
 // If [WithStdoutConsumer] set, you can get the standard output of a command without waiting for the command to finish.
 // If [WithStderrConsumer] set, you can get the standard error of a command without waiting for the command to finish.
-// [WithSplitFunc] sets the split function for a scanner used in consumers, default is [bufio.ScanLines].
-// default is `[]byte("\n")`.
+// [WithDelim] sets the delimiter of tokens passed to consumers, default is '\n'.
+// [WithTokenTransform] converts tokens before they are passed to consumers, e.g. [StripANSI],
+// this does not affect the data written to [Cmd.Stdout] and [Cmd.Stderr].
+// The values of the secret variables in tokens are redacted, see [Env.SetSecret].
 func (c Cmd) Run(ctx context.Context, opt ...Option) (*Result, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -129,6 +198,7 @@ func (c Cmd) Run(ctx context.Context, opt ...Option) (*Result, error) {
 		Delim('\n').
 		CaptureStdout(false).
 		CaptureStderr(false).
+		TokenTransform(NopTokenTransform).
 		Build()
 	config.Apply(opt...)
 
@@ -175,13 +245,23 @@ func (c Cmd) runWithLineConsumers(
 		return nil, fmt.Errorf("%w: command start", err)
 	}
 
-	worker := func(w io.Writer, r io.Reader, consumer func(Token)) func() error {
-		s := NewScanner(w, r, cfg.Delim.Get(), consumer)
+	transform := cfg.TokenTransform.Get()
+	if result.redactor.enabled() {
+		transform = ChainTokenTransforms(transform, result.redactor.redactToken)
+	}
+	stderrTransform := transform
+	if c.stderrTransform != nil {
+		stderrTransform = ChainTokenTransforms(c.stderrTransform, transform)
+	}
+	worker := func(w io.Writer, r io.Reader, consumer func(Token), transform TokenTransform) func() error {
+		s := NewScanner(w, r, cfg.Delim.Get(), func(t Token) {
+			consumer(transform(t))
+		})
 		return s.Scan
 	}
 	eg, _ := errgroup.WithContext(ctx)
-	eg.Go(worker(writers.stdout, stdout, cfg.StdoutConsumer.Get()))
-	eg.Go(worker(writers.stderr, stderr, cfg.StderrConsumer.Get()))
+	eg.Go(worker(writers.stdout, stdout, cfg.StdoutConsumer.Get(), transform))
+	eg.Go(worker(writers.stderr, stderr, cfg.StderrConsumer.Get(), stderrTransform))
 
 	if err := eg.Wait(); err != nil {
 		return nil, fmt.Errorf("%w: read wait", err)
@@ -204,5 +284,5 @@ func (c Cmd) Exec() error {
 	if err := os.Chdir(c.Dir); err != nil {
 		return fmt.Errorf("%w: exec chdir %s", err, c.Dir)
 	}
-	return syscall.Exec(bin, c.Args, c.Env.IntoSlice())
+	return syscall.Exec(bin, c.Args, c.Environ().IntoSlice())
 }
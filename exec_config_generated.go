@@ -1,4 +1,4 @@
-// Code generated by "goconfig -field StdoutConsumer func(Token)|StderrConsumer func(Token)|Delim byte|CaptureStdout bool|CaptureStderr bool -option -output exec_config_generated.go -configOption Option"; DO NOT EDIT.
+// Code generated by "goconfig -field StdoutConsumer func(Token)|StderrConsumer func(Token)|Delim byte|CaptureStdout bool|CaptureStderr bool|TokenTransform TokenTransform -option -output exec_config_generated.go -configOption Option"; DO NOT EDIT.
 
 package execx
 
@@ -36,6 +36,7 @@ type Config struct {
 	Delim          *ConfigItem[byte]
 	CaptureStdout  *ConfigItem[bool]
 	CaptureStderr  *ConfigItem[bool]
+	TokenTransform *ConfigItem[TokenTransform]
 }
 type ConfigBuilder struct {
 	stdoutConsumer func(Token)
@@ -43,6 +44,7 @@ type ConfigBuilder struct {
 	delim          byte
 	captureStdout  bool
 	captureStderr  bool
+	tokenTransform TokenTransform
 }
 
 func (s *ConfigBuilder) StdoutConsumer(v func(Token)) *ConfigBuilder {
@@ -65,6 +67,10 @@ func (s *ConfigBuilder) CaptureStderr(v bool) *ConfigBuilder {
 	s.captureStderr = v
 	return s
 }
+func (s *ConfigBuilder) TokenTransform(v TokenTransform) *ConfigBuilder {
+	s.tokenTransform = v
+	return s
+}
 func (s *ConfigBuilder) Build() *Config {
 	return &Config{
 		StdoutConsumer: NewConfigItem(s.stdoutConsumer),
@@ -72,6 +78,7 @@ func (s *ConfigBuilder) Build() *Config {
 		Delim:          NewConfigItem(s.delim),
 		CaptureStdout:  NewConfigItem(s.captureStdout),
 		CaptureStderr:  NewConfigItem(s.captureStderr),
+		TokenTransform: NewConfigItem(s.tokenTransform),
 	}
 }
 
@@ -109,3 +116,8 @@ func WithCaptureStderr(v bool) Option {
 		c.CaptureStderr.Set(v)
 	}
 }
+func WithTokenTransform(v TokenTransform) Option {
+	return func(c *Config) {
+		c.TokenTransform.Set(v)
+	}
+}
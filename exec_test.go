@@ -5,6 +5,8 @@ import (
 	"context"
 	"io"
 	"os"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/berquerant/execx"
@@ -85,6 +87,71 @@ func TestCmd(t *testing.T) {
 			assertReader(t, bytes.NewBufferString("added:append1\n"), r.Stdout)
 		})
 
+		t.Run("expand unset", func(t *testing.T) {
+			cmd := execx.New("echo", "${NAME:-anon}", "${#NAME}", "[${NAME:+alt}]", "${GREETING:=hi}", "$GREETING", "$NAME")
+			cmd.Env.Set("GREETING", "hello")
+			r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"echo", "anon", "0", "[]", "hello", "hello", "$NAME"}, r.ExpandedArgs)
+			assertReader(t, bytes.NewBufferString("anon 0 [] hello hello $NAME\n"), r.Stdout)
+		})
+
+		t.Run("inherit", func(t *testing.T) {
+			t.Setenv("test_cmd_inherit_env1", "inherit1")
+			t.Setenv("test_cmd_inherit_env2", "inherit2")
+			for _, tc := range []struct {
+				name    string
+				inherit execx.InheritMode
+				allow   []string
+				want    []string
+			}{
+				{
+					name:    "none",
+					inherit: execx.InheritNone,
+					want: []string{
+						"test_cmd_inherit_env0=explicit",
+						"test_cmd_inherit_env2=override:$test_cmd_inherit_env2",
+					},
+				},
+				{
+					name:    "full",
+					inherit: execx.InheritFull,
+					want: []string{
+						"test_cmd_inherit_env0=explicit",
+						"test_cmd_inherit_env1=inherit1",
+						"test_cmd_inherit_env2=override:inherit2",
+					},
+				},
+				{
+					name:    "allow list",
+					inherit: execx.InheritAllowList,
+					allow:   []string{"test_cmd_inherit_*1"},
+					want: []string{
+						"test_cmd_inherit_env0=explicit",
+						"test_cmd_inherit_env1=inherit1",
+						"test_cmd_inherit_env2=override:$test_cmd_inherit_env2",
+					},
+				},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					cmd := execx.New("env")
+					cmd.Env.Set("test_cmd_inherit_env0", "explicit")
+					cmd.Env.Set("test_cmd_inherit_env2", "override:$test_cmd_inherit_env2")
+					cmd.Inherit = tc.inherit
+					cmd.InheritAllow = tc.allow
+					var got []string
+					_, err := cmd.Run(context.TODO(), execx.WithStdoutConsumer(func(x execx.Token) {
+						if s := x.String(); strings.HasPrefix(s, "test_cmd_inherit_") {
+							got = append(got, s)
+						}
+					}))
+					assert.Nil(t, err)
+					sort.Strings(got)
+					assert.Equal(t, tc.want, got)
+				})
+			}
+		})
+
 		for _, tc := range []struct {
 			name string
 			cmd  *execx.Cmd
@@ -0,0 +1,451 @@
+package execx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/berquerant/execx/internal"
+)
+
+var (
+	ErrUnresolvedVariable = errors.New("UnresolvedVariable")
+)
+
+// Expand expands environment variables in target.
+//
+// Supports the following forms of shell parameter expansion:
+//
+//	$VAR, ${VAR}
+//	${VAR:-default}, ${VAR-default}   use default if VAR is unset (or empty)
+//	${VAR:=default}, ${VAR=default}   set default to VAR if VAR is unset (or empty)
+//	${VAR:?message}, ${VAR?message}   error if VAR is unset (or empty)
+//	${VAR:+alt}, ${VAR+alt}           use alt if VAR is set (and not empty)
+//	${#VAR}                           length of VAR
+//	${VAR#pattern}, ${VAR##pattern}   remove the shortest (longest) matching prefix
+//	${VAR%pattern}, ${VAR%%pattern}   remove the shortest (longest) matching suffix
+//	${VAR/pattern/string}             replace the first match
+//	${VAR//pattern/string}            replace all matches
+//	${VAR/#pattern/string}            replace the matching prefix
+//	${VAR/%pattern/string}            replace the matching suffix
+//
+// Values of variables are also expanded.
+// Expressions of the variables not in the Env, e.g. shell variables and positional parameters,
+// and circular references are left as they are, so that the shell expands them.
+// See [Env.ExpandStrict] to resolve or detect them.
+// Assignments by ${VAR:=default} are visible only in the expansion, the Env is not changed.
+func (e Env) Expand(target string) string {
+	x := &expander{
+		env: e,
+	}
+	return x.expand(target)
+}
+
+// ExpandStrict is [Env.Expand] but returns an error instead of leaving unresolved expressions.
+//
+// The default, assignment and alternative forms are applied to the variables not in the Env.
+// Returns a [*CycleError] if variables refer to each other circularly,
+// otherwise [ErrUnresolvedVariable] listing unresolved variables.
+func (e Env) ExpandStrict(target string) (string, error) {
+	x := &expander{
+		env:    e,
+		strict: true,
+	}
+	result := x.expand(target)
+	if err := x.err(); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// expandValue is [Env.Expand] for the values no shell expands later, e.g. arguments of commands.
+//
+// The default, assignment, alternative and length forms are applied to the variables not in the Env.
+func (e Env) expandValue(target string) string {
+	x := &expander{
+		env:     e,
+		resolve: true,
+	}
+	return x.expand(target)
+}
+
+// ExpandStrings expands environment variables in multiple targets.
+func (e Env) ExpandStrings(target []string) []string {
+	result := make([]string, len(target))
+	for i, t := range target {
+		result[i] = e.Expand(t)
+	}
+	return result
+}
+
+// ExpandStringsStrict expands environment variables in multiple targets by [Env.ExpandStrict].
+func (e Env) ExpandStringsStrict(target []string) ([]string, error) {
+	result := make([]string, len(target))
+	for i, t := range target {
+		r, err := e.ExpandStrict(t)
+		if err != nil {
+			return nil, fmt.Errorf("%w: target[%d]", err, i)
+		}
+		result[i] = r
+	}
+	return result, nil
+}
+
+type expander struct {
+	env    Env
+	strict bool
+	// resolve applies the forms with defaults to the variables not in the Env in lenient mode
+	resolve bool
+	// variables being expanded
+	stack []string
+	// unresolved variables and messages
+	unresolved []string
+	// the first circular reference
+	cycle []string
+	// variables assigned by ${VAR:=default}
+	assigned map[string]string
+}
+
+func (x *expander) err() error {
+	if x.cycle != nil {
+		return &CycleError{
+			Cycle: x.cycle,
+		}
+	}
+	if len(x.unresolved) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnresolvedVariable, strings.Join(x.unresolved, ", "))
+}
+
+func (x *expander) addUnresolved(item string) {
+	for _, u := range x.unresolved {
+		if u == item {
+			return
+		}
+	}
+	x.unresolved = append(x.unresolved, item)
+}
+
+func (x *expander) expand(s string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		n, r := x.expandParam(s[i:])
+		b.WriteString(r)
+		s = s[i+n:]
+	}
+}
+
+// expandParam expands a parameter at the head of s, s begins with '$'.
+// Returns the consumed length and the result.
+func (x *expander) expandParam(s string) (int, string) {
+	if len(s) < 2 {
+		return 1, "$"
+	}
+	if s[1] == '{' {
+		end := closingBrace(s, 2)
+		if end < 0 {
+			return 1, "$"
+		}
+		return end + 1, x.expandBraced(s[2:end], s[:end+1])
+	}
+	name := scanParamName(s[1:], false)
+	if name == "" {
+		return 1, "$"
+	}
+	raw := s[:1+len(name)]
+	v, ok := x.lookup(name)
+	if !ok {
+		x.unresolvedParam(name)
+		return len(raw), raw
+	}
+	return len(raw), v
+}
+
+// closingBrace returns the index of '}' closing the brace opened before i, or -1.
+func closingBrace(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				depth++
+				i++
+			}
+		case '\\':
+			i++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func isNameHead(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isNameTail(c byte) bool {
+	return isNameHead(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isSpecialParam(c byte) bool {
+	return strings.IndexByte("*#$@!?-", c) >= 0
+}
+
+// scanParamName returns the parameter name at the head of s.
+// If braced is true, multi-digit positional parameters are allowed.
+func scanParamName(s string, braced bool) string {
+	if s == "" {
+		return ""
+	}
+	switch c := s[0]; {
+	case isNameHead(c):
+		i := 1
+		for i < len(s) && isNameTail(s[i]) {
+			i++
+		}
+		return s[:i]
+	case isDigit(c):
+		if !braced {
+			return s[:1]
+		}
+		i := 1
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+		return s[:i]
+	case isSpecialParam(c):
+		return s[:1]
+	default:
+		return ""
+	}
+}
+
+// lookup returns the expanded value of the variable.
+func (x *expander) lookup(name string) (string, bool) {
+	if v, ok := x.assigned[name]; ok {
+		return v, true
+	}
+	v, ok := x.env.Get(name)
+	if !ok {
+		return "", false
+	}
+	for i, s := range x.stack {
+		if s == name {
+			if x.cycle == nil {
+				x.cycle = append(append([]string{}, x.stack[i:]...), name)
+			}
+			return "", false
+		}
+	}
+	x.stack = append(x.stack, name)
+	defer func() {
+		x.stack = x.stack[:len(x.stack)-1]
+	}()
+	return x.expand(v), true
+}
+
+func (x *expander) unresolvedParam(name string) {
+	if x.strict {
+		x.addUnresolved(name)
+	}
+}
+
+// expandBraced expands ${content}, raw is the whole expression.
+func (x *expander) expandBraced(content, raw string) string {
+	if len(content) > 1 && content[0] == '#' {
+		if name := scanParamName(content[1:], true); len(name) == len(content)-1 {
+			v, ok := x.lookup(name)
+			if !ok && x.resolve {
+				return "0"
+			}
+			if !ok {
+				x.unresolvedParam(name)
+				return raw
+			}
+			return strconv.Itoa(utf8.RuneCountInString(v))
+		}
+	}
+
+	name := scanParamName(content, true)
+	if name == "" {
+		return raw
+	}
+	var (
+		rest   = content[len(name):]
+		v, set = x.lookup(name)
+		null   = !set || v == ""
+	)
+
+	if !set && !x.strict && !x.resolve {
+		// may be expanded by the shell
+		return raw
+	}
+	if rest == "" {
+		if !set {
+			x.unresolvedParam(name)
+			return raw
+		}
+		return v
+	}
+
+	var op string
+	for _, o := range []string{":-", ":=", ":?", ":+", "-", "=", "?", "+", "##", "#", "%%", "%", "//", "/#", "/%", "/"} {
+		if strings.HasPrefix(rest, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return raw
+	}
+	word := rest[len(op):]
+
+	switch op {
+	case ":-", "-":
+		if !set || (op == ":-" && null) {
+			return x.expand(word)
+		}
+		return v
+	case ":=", "=":
+		if !set || (op == ":=" && null) {
+			w := x.expand(word)
+			if x.assigned == nil {
+				x.assigned = map[string]string{}
+			}
+			x.assigned[name] = w
+			return w
+		}
+		return v
+	case ":?", "?":
+		if !set || (op == ":?" && null) {
+			if x.strict {
+				msg := x.expand(word)
+				if msg == "" {
+					msg = "parameter null or not set"
+				}
+				x.addUnresolved(fmt.Sprintf("%s: %s", name, msg))
+			}
+			return raw
+		}
+		return v
+	case ":+", "+":
+		if !set || (op == ":+" && null) {
+			return ""
+		}
+		return x.expand(word)
+	}
+
+	if !set {
+		x.unresolvedParam(name)
+		return raw
+	}
+
+	switch op {
+	case "#", "##", "%", "%%":
+		return removePattern(v, x.expand(word), op)
+	default:
+		pattern, replacement := cutUnescaped(word, '/')
+		return replacePattern(v, x.expand(pattern), x.expand(replacement), op)
+	}
+}
+
+// cutUnescaped slices s around the first sep not escaped by a backslash.
+func cutUnescaped(s string, sep byte) (string, string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+func compileGlob(pattern string, prefix, suffix string) (*regexp.Regexp, error) {
+	return regexp.Compile(prefix + "(?:" + internal.GlobRegexp(pattern) + ")" + suffix)
+}
+
+func removePattern(v, pattern, op string) string {
+	re, err := compileGlob(pattern, "^", "$")
+	if err != nil {
+		return v
+	}
+	n := len(v)
+	switch op {
+	case "#":
+		for i := 0; i <= n; i++ {
+			if re.MatchString(v[:i]) {
+				return v[i:]
+			}
+		}
+	case "##":
+		for i := n; i >= 0; i-- {
+			if re.MatchString(v[:i]) {
+				return v[i:]
+			}
+		}
+	case "%":
+		for i := n; i >= 0; i-- {
+			if re.MatchString(v[i:]) {
+				return v[:i]
+			}
+		}
+	case "%%":
+		for i := 0; i <= n; i++ {
+			if re.MatchString(v[i:]) {
+				return v[:i]
+			}
+		}
+	}
+	return v
+}
+
+func replacePattern(v, pattern, replacement, op string) string {
+	if pattern == "" {
+		return v
+	}
+	var (
+		re  *regexp.Regexp
+		err error
+	)
+	switch op {
+	case "/#":
+		re, err = compileGlob(pattern, "^", "")
+	case "/%":
+		re, err = compileGlob(pattern, "", "$")
+	default:
+		re, err = compileGlob(pattern, "", "")
+	}
+	if err != nil {
+		return v
+	}
+	re.Longest()
+
+	if op == "//" {
+		return re.ReplaceAllLiteralString(v, replacement)
+	}
+	loc := re.FindStringIndex(v)
+	if loc == nil {
+		return v
+	}
+	return v[:loc[0]] + replacement + v[loc[1]:]
+}
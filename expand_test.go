@@ -0,0 +1,243 @@
+package execx_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	newEnv := func() execx.Env {
+		return execx.EnvFromSlice([]string{
+			"EMPTY=",
+			"PATHNAME=/usr/local/lib/file.tar.gz",
+			"NAME=world",
+			"REF=hello ${NAME}",
+			"MULTI=あいう",
+		})
+	}
+
+	for _, tc := range []struct {
+		title  string
+		target string
+		want   string
+		// result in strict mode if different from want
+		strictWant string
+		// unresolved variables and messages, empty if no errors in strict mode
+		strictErr string
+	}{
+		{
+			title:  "no variables",
+			target: "plain text",
+			want:   "plain text",
+		},
+		{
+			title:  "simple",
+			target: "$NAME ${NAME} ${NAME}s",
+			want:   "world world worlds",
+		},
+		{
+			title:  "nested reference",
+			target: "$REF!",
+			want:   "hello world!",
+		},
+		{
+			title:  "lone dollar",
+			target: "$ 100$ ${ $-",
+			want:   "$ 100$ ${ $-",
+			// $- is a special parameter
+			strictErr: "-",
+		},
+		{
+			title:     "missing",
+			target:    "$A ${A} $NAME $B",
+			want:      "$A ${A} world $B",
+			strictErr: "A, B",
+		},
+		{
+			title:     "positional",
+			target:    "$1 ${10}",
+			want:      "$1 ${10}",
+			strictErr: "1, 10",
+		},
+		{
+			title:  "bad substitution",
+			target: "${} ${NAME!}",
+			want:   "${} ${NAME!}",
+		},
+		{
+			title:  "default set",
+			target: "${NAME:-d} ${NAME-d}",
+			want:   "world world",
+		},
+		{
+			title:  "default empty",
+			target: "${EMPTY:-d} [${EMPTY-d}]",
+			want:   "d []",
+		},
+		{
+			title:      "default unset",
+			target:     "${A:-d} ${A-d}",
+			want:       "${A:-d} ${A-d}",
+			strictWant: "d d",
+		},
+		{
+			title:      "default expanded",
+			target:     "${A:-${NAME}!}",
+			want:       "${A:-${NAME}!}",
+			strictWant: "world!",
+		},
+		{
+			title:     "default unresolved",
+			target:    "${A:-$B}",
+			want:      "${A:-$B}",
+			strictErr: "B",
+		},
+		{
+			title:      "alternative",
+			target:     "[${NAME:+alt}] [${EMPTY:+alt}] [${EMPTY+alt}] [${A:+alt}] [${A+alt}]",
+			want:       "[alt] [] [alt] [${A:+alt}] [${A+alt}]",
+			strictWant: "[alt] [] [alt] [] []",
+		},
+		{
+			title:  "error set",
+			target: "${NAME:?required}",
+			want:   "world",
+		},
+		{
+			title:     "error unset",
+			target:    "${A:?is required} ${EMPTY?not checked}",
+			want:      "${A:?is required} ",
+			strictErr: "A: is required",
+		},
+		{
+			title:     "error empty",
+			target:    "${EMPTY:?}",
+			want:      "${EMPTY:?}",
+			strictErr: "EMPTY: parameter null or not set",
+		},
+		{
+			title:  "length",
+			target: "${#NAME} ${#EMPTY} ${#MULTI}",
+			want:   "5 0 3",
+		},
+		{
+			title:     "length unset",
+			target:    "${#A}",
+			want:      "${#A}",
+			strictErr: "A",
+		},
+		{
+			title:  "remove prefix",
+			target: "${PATHNAME#*/} ${PATHNAME##*/}",
+			want:   "usr/local/lib/file.tar.gz file.tar.gz",
+		},
+		{
+			title:  "remove suffix",
+			target: "${PATHNAME%.*} ${PATHNAME%%.*}",
+			want:   "/usr/local/lib/file.tar /usr/local/lib/file",
+		},
+		{
+			title:  "remove not matched",
+			target: "${NAME#x} ${NAME%x}",
+			want:   "world world",
+		},
+		{
+			title:  "remove with expanded pattern",
+			target: "${REF%$NAME}",
+			want:   "hello ",
+		},
+		{
+			title:     "remove unset",
+			target:    "${A#x}",
+			want:      "${A#x}",
+			strictErr: "A",
+		},
+		{
+			title:  "replace first",
+			target: "${PATHNAME/l/L}",
+			want:   "/usr/Local/lib/file.tar.gz",
+		},
+		{
+			title:  "replace all",
+			target: "${PATHNAME//l/L}",
+			want:   "/usr/LocaL/Lib/fiLe.tar.gz",
+		},
+		{
+			title:  "replace longest",
+			target: "${PATHNAME/\\/*\\//}",
+			want:   "file.tar.gz",
+		},
+		{
+			title:  "replace prefix",
+			target: "${NAME/#w/W} ${NAME/#o/O}",
+			want:   "World world",
+		},
+		{
+			title:  "replace suffix",
+			target: "${NAME/%d/D} ${NAME/%o/O}",
+			want:   "worlD world",
+		},
+		{
+			title:  "delete",
+			target: "${NAME/o}",
+			want:   "wrld",
+		},
+		{
+			title:     "awk",
+			target:    `'{printf "%s:%s", $NAME, $2}'`,
+			want:      `'{printf "%s:%s", world, $2}'`,
+			strictErr: "2",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			t.Run("lenient", func(t *testing.T) {
+				assert.Equal(t, tc.want, newEnv().Expand(tc.target))
+			})
+			t.Run("strict", func(t *testing.T) {
+				got, err := newEnv().ExpandStrict(tc.target)
+				if tc.strictErr != "" {
+					assert.ErrorIs(t, err, execx.ErrUnresolvedVariable)
+					assert.Equal(t, "UnresolvedVariable: "+tc.strictErr, err.Error())
+					return
+				}
+				assert.Nil(t, err)
+				want := tc.want
+				if tc.strictWant != "" {
+					want = tc.strictWant
+				}
+				assert.Equal(t, want, got)
+			})
+		})
+	}
+
+	t.Run("assign default", func(t *testing.T) {
+		e := newEnv()
+		assert.Equal(t, "${A:=d} world world", e.Expand("${A:=d} ${EMPTY:=$NAME} $EMPTY"))
+		got, err := e.ExpandStrict("${A:=d} $A ${NAME:=d}")
+		assert.Nil(t, err)
+		assert.Equal(t, "d d world", got)
+
+		_, ok := e.Get("A")
+		assert.False(t, ok, "env should not be changed")
+		got, _ = e.Get("EMPTY")
+		assert.Equal(t, "", got, "env should not be changed")
+	})
+
+	t.Run("shell parameters", func(t *testing.T) {
+		e := newEnv()
+		const target = `x=local; echo "${1:-world} ${x:-none} ${y:+alt} ${#z} ${x%l*}"`
+		assert.Equal(t, target, e.Expand(target))
+	})
+
+	t.Run("strings strict", func(t *testing.T) {
+		e := newEnv()
+		got, err := e.ExpandStringsStrict([]string{"$NAME", "${#NAME}"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"world", "5"}, got)
+		_, err = e.ExpandStringsStrict([]string{"$NAME", "$A"})
+		assert.ErrorIs(t, err, execx.ErrUnresolvedVariable)
+		assert.ErrorContains(t, err, "target[1]")
+	})
+}
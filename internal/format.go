@@ -5,18 +5,6 @@ import (
 	"strings"
 )
 
-func EscapeQuote(s string) string {
-	return strings.ReplaceAll(s, `"`, `\"`)
-}
-
-func EscapeQuoteAndJoin(ss []string) string {
-	r := make([]string, len(ss))
-	for i, s := range ss {
-		r[i] = EscapeQuote(s)
-	}
-	return strings.Join(r, " ")
-}
-
 var (
 	sprintfRegex = regexp.MustCompile(`%\[[^]]+\]`)
 )
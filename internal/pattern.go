@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GlobRegexp converts a shell pattern into an unanchored regular expression.
+//
+// Supports *, ?, bracket expressions ([abc], [!abc], [a-z]) and backslash escapes.
+func GlobRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("(?s)")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(`\`))
+		case '[':
+			end := bracketEnd(pattern, i)
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			b.WriteString(bracketRegexp(pattern[i+1 : end]))
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// bracketEnd returns the index of ] closing the bracket expression starting at i, or -1.
+func bracketEnd(pattern string, i int) int {
+	j := i + 1
+	if j < len(pattern) && (pattern[j] == '!' || pattern[j] == '^') {
+		j++
+	}
+	if j < len(pattern) && pattern[j] == ']' {
+		j++
+	}
+	for ; j < len(pattern); j++ {
+		if pattern[j] == ']' {
+			return j
+		}
+	}
+	return -1
+}
+
+func bracketRegexp(body string) string {
+	var b strings.Builder
+	b.WriteString("[")
+	if len(body) > 0 && (body[0] == '!' || body[0] == '^') {
+		b.WriteString("^")
+		body = body[1:]
+	}
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; c {
+		case '\\', '[', ']', '^':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
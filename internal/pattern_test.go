@@ -0,0 +1,40 @@
+package internal_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/berquerant/execx/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{pattern: "", s: "", want: true},
+		{pattern: "abc", s: "abc", want: true},
+		{pattern: "abc", s: "abd"},
+		{pattern: "a*", s: "a/b/c", want: true},
+		{pattern: "a?c", s: "abc", want: true},
+		{pattern: "a?c", s: "ac"},
+		{pattern: "*.go", s: "main.go", want: true},
+		{pattern: "[abc]x", s: "bx", want: true},
+		{pattern: "[!abc]x", s: "bx"},
+		{pattern: "[!abc]x", s: "dx", want: true},
+		{pattern: "[a-c]", s: "b", want: true},
+		{pattern: "[]]", s: "]", want: true},
+		{pattern: `\*`, s: "*", want: true},
+		{pattern: `\*`, s: "a"},
+		{pattern: "[", s: "[", want: true},
+		{pattern: "a.b", s: "axb"},
+		{pattern: "a*", s: "a\nb", want: true},
+	} {
+		t.Run(tc.pattern+" "+tc.s, func(t *testing.T) {
+			re := regexp.MustCompile("^(?:" + internal.GlobRegexp(tc.pattern) + ")$")
+			assert.Equal(t, tc.want, re.MatchString(tc.s))
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package execx
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Interpreter describes how to run scripts.
+type Interpreter struct {
+	// Shell executes scripts, e.g. ["python3"].
+	Shell []string
+	// Extension is the extension of script files, e.g. ".py".
+	Extension string
+	// Preamble is inserted at the head of scripts, e.g. "set -euo pipefail".
+	Preamble string
+	// StdinArgs are passed to Shell to read a script from stdin, e.g. ["-s"] for sh.
+	// See [DeliverStdin].
+	StdinArgs []string
+	// InlineArgs are passed to Shell before a script, e.g. ["-c"] for sh.
+	// See [DeliverArg].
+	InlineArgs []string
+	// InlineArg0 is passed to Shell after a script if not empty, it becomes $0 of sh -c.
+	// See [DeliverArg].
+	InlineArg0 string
+	// SyntaxCheck are passed to Shell before a script file to check the syntax without execution,
+	// e.g. ["-n"] for sh.
+	// See [Script.Validate].
+	SyntaxCheck []string
+}
+
+// pythonSyntaxCheck compiles a script without writing bytecode files unlike py_compile.
+const pythonSyntaxCheck = `import sys; compile(open(sys.argv[1]).read(), sys.argv[1], "exec")`
+
+var (
+	InterpreterSh = Interpreter{
+		Shell:       []string{"sh"},
+		Extension:   ".sh",
+		Preamble:    "set -eu",
+		StdinArgs:   []string{"-s"},
+		InlineArgs:  []string{"-c"},
+		InlineArg0:  "execx",
+		SyntaxCheck: []string{"-n"},
+	}
+	InterpreterBash = Interpreter{
+		Shell:       []string{"bash"},
+		Extension:   ".sh",
+		Preamble:    "set -euo pipefail",
+		StdinArgs:   []string{"-s"},
+		InlineArgs:  []string{"-c"},
+		InlineArg0:  "execx",
+		SyntaxCheck: []string{"-n"},
+	}
+	InterpreterZsh = Interpreter{
+		Shell:       []string{"zsh"},
+		Extension:   ".zsh",
+		Preamble:    "set -euo pipefail",
+		StdinArgs:   []string{"-s"},
+		InlineArgs:  []string{"-c"},
+		InlineArg0:  "execx",
+		SyntaxCheck: []string{"-n"},
+	}
+	InterpreterPython = Interpreter{
+		Shell:       []string{"python3"},
+		Extension:   ".py",
+		StdinArgs:   []string{"-"},
+		InlineArgs:  []string{"-c"},
+		SyntaxCheck: []string{"-c", pythonSyntaxCheck},
+	}
+	InterpreterNode = Interpreter{
+		Shell:       []string{"node"},
+		Extension:   ".js",
+		StdinArgs:   []string{"-"},
+		InlineArgs:  []string{"-e"},
+		SyntaxCheck: []string{"--check"},
+	}
+	InterpreterPerl = Interpreter{
+		Shell:       []string{"perl"},
+		Extension:   ".pl",
+		Preamble:    "use strict;\nuse warnings;",
+		StdinArgs:   []string{"-"},
+		InlineArgs:  []string{"-e"},
+		SyntaxCheck: []string{"-c"},
+	}
+	InterpreterRuby = Interpreter{
+		Shell:       []string{"ruby"},
+		Extension:   ".rb",
+		StdinArgs:   []string{"-"},
+		InlineArgs:  []string{"-e"},
+		SyntaxCheck: []string{"-c"},
+	}
+)
+
+var knownInterpreters = map[string]Interpreter{
+	"sh":      InterpreterSh,
+	"bash":    InterpreterBash,
+	"zsh":     InterpreterZsh,
+	"python":  InterpreterPython,
+	"python3": InterpreterPython,
+	"node":    InterpreterNode,
+	"perl":    InterpreterPerl,
+	"ruby":    InterpreterRuby,
+}
+
+// LookupInterpreter returns the known [Interpreter] by the name of the shell, e.g. "bash", "/usr/bin/python3".
+//
+// The Shell of the result is the given shell.
+func LookupInterpreter(shell string, arg ...string) (Interpreter, bool) {
+	x, ok := knownInterpreters[filepath.Base(shell)]
+	if !ok {
+		return Interpreter{}, false
+	}
+	x.Shell = append([]string{shell}, arg...)
+	return x, true
+}
+
+// shebang returns a shebang line to execute the script by the shell.
+func (i Interpreter) shebang() string {
+	if filepath.IsAbs(i.Shell[0]) {
+		return "#!" + strings.Join(i.Shell, " ")
+	}
+	if len(i.Shell) == 1 {
+		return "#!/usr/bin/env " + i.Shell[0]
+	}
+	// passes multiple arguments to env
+	return "#!/usr/bin/env -S " + strings.Join(i.Shell, " ")
+}
@@ -0,0 +1,124 @@
+package execx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvLayer is a named scope of [LayeredEnv].
+type EnvLayer struct {
+	Name string
+	Env  Env
+}
+
+// LayeredEnv is a stack of [Env], later layers override earlier ones.
+//
+// Values are resolved by merging the layers from the bottom by [Env.Merge],
+// so that a layer can refer to the values of the lower layers, e.g. PATH=$PATH:/bin.
+type LayeredEnv struct {
+	layers []*EnvLayer
+}
+
+// NewLayeredEnv creates a new empty [LayeredEnv].
+func NewLayeredEnv() *LayeredEnv {
+	return &LayeredEnv{}
+}
+
+// Push adds a layer on the top.
+func (l *LayeredEnv) Push(name string, env Env) *LayeredEnv {
+	l.layers = append(l.layers, &EnvLayer{
+		Name: name,
+		Env:  env,
+	})
+	return l
+}
+
+// Layers returns the layers from the bottom.
+func (l *LayeredEnv) Layers() []*EnvLayer {
+	return l.layers
+}
+
+// Layer returns the topmost layer with the name.
+func (l *LayeredEnv) Layer(name string) (*EnvLayer, bool) {
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		if x := l.layers[i]; x.Name == name {
+			return x, true
+		}
+	}
+	return nil, false
+}
+
+// Flatten merges the layers into a new [Env].
+func (l *LayeredEnv) Flatten() Env {
+	env := NewEnv()
+	for _, x := range l.layers {
+		env.Merge(x.Env)
+	}
+	return env
+}
+
+// Get returns the resolved value of the variable.
+func (l *LayeredEnv) Get(key string) (string, bool) {
+	return l.Flatten().Get(key)
+}
+
+// Source returns the name of the topmost layer that defines the variable.
+func (l *LayeredEnv) Source(key string) (string, bool) {
+	origins := l.Origins(key)
+	if len(origins) == 0 {
+		return "", false
+	}
+	return origins[0].Layer, true
+}
+
+// EnvOrigin is a definition of a variable in a layer.
+type EnvOrigin struct {
+	Layer string
+	// Value is the value in the layer, not resolved.
+	Value string
+}
+
+// Origins returns the definitions of the variable from the top.
+func (l *LayeredEnv) Origins(key string) []EnvOrigin {
+	var result []EnvOrigin
+	for i := len(l.layers) - 1; i >= 0; i-- {
+		x := l.layers[i]
+		if v, ok := x.Env.Get(key); ok {
+			result = append(result, EnvOrigin{
+				Layer: x.Name,
+				Value: v,
+			})
+		}
+	}
+	return result
+}
+
+// Explain describes why the variable has its value.
+//
+//	PATH=/bin:/usr/local/bin (from ci)
+//	  ci: $PATH:/usr/local/bin
+//	  os: /bin
+//
+// The values of the secret variables are redacted.
+func (l *LayeredEnv) Explain(key string) string {
+	var (
+		env    = l.Flatten()
+		b      strings.Builder
+		redact = func(k, v string) string {
+			if env.IsSecret(k) {
+				return RedactedValue
+			}
+			return env.Redact(v)
+		}
+	)
+	origins := l.Origins(key)
+	if len(origins) == 0 {
+		return fmt.Sprintf("%s is not set", key)
+	}
+	v, _ := env.Get(key)
+	fmt.Fprintf(&b, "%s=%s (from %s)", key, redact(key, v), origins[0].Layer)
+	for _, x := range origins {
+		fmt.Fprintf(&b, "\n  %s: %s", x.Layer, redact(key, x.Value))
+	}
+	return b.String()
+}
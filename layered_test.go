@@ -0,0 +1,86 @@
+package execx_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayeredEnv(t *testing.T) {
+	newLayeredEnv := func() *execx.LayeredEnv {
+		secret := execx.NewEnv()
+		secret.SetSecret("TOKEN", "s3cr3t")
+		return execx.NewLayeredEnv().
+			Push("os", execx.EnvFromSlice([]string{"PATH=/bin", "HOME=/home/u", "CI=false"})).
+			Push("dotenv", execx.EnvFromSlice([]string{"PATH=$PATH:/usr/local/bin", "APP=app"})).
+			Push("ci", execx.EnvFromSlice([]string{"CI=true"})).
+			Push("secret", secret).
+			Push("task", execx.EnvFromSlice([]string{"APP=task-$APP", "AUTH=token $TOKEN"}))
+	}
+
+	t.Run("layers", func(t *testing.T) {
+		l := newLayeredEnv()
+		assert.Equal(t, 5, len(l.Layers()))
+		x, ok := l.Layer("ci")
+		assert.True(t, ok)
+		assert.Equal(t, "ci", x.Name)
+		_, ok = l.Layer("unknown")
+		assert.False(t, ok)
+	})
+
+	t.Run("flatten", func(t *testing.T) {
+		e := newLayeredEnv().Flatten()
+		assert.Equal(t, []string{
+			"PATH=/bin:/usr/local/bin",
+			"HOME=/home/u",
+			"CI=true",
+			"APP=task-app",
+			"TOKEN=s3cr3t",
+			"AUTH=token $TOKEN",
+		}, e.IntoSlice())
+		assert.True(t, e.IsSecret("TOKEN"))
+	})
+
+	t.Run("get", func(t *testing.T) {
+		l := newLayeredEnv()
+		for _, tc := range []struct {
+			key    string
+			value  string
+			source string
+			ok     bool
+		}{
+			{key: "PATH", value: "/bin:/usr/local/bin", source: "dotenv", ok: true},
+			{key: "HOME", value: "/home/u", source: "os", ok: true},
+			{key: "APP", value: "task-app", source: "task", ok: true},
+			{key: "UNKNOWN"},
+		} {
+			t.Run(tc.key, func(t *testing.T) {
+				v, ok := l.Get(tc.key)
+				assert.Equal(t, tc.ok, ok)
+				assert.Equal(t, tc.value, v)
+				s, ok := l.Source(tc.key)
+				assert.Equal(t, tc.ok, ok)
+				assert.Equal(t, tc.source, s)
+			})
+		}
+	})
+
+	t.Run("origins", func(t *testing.T) {
+		assert.Equal(t, []execx.EnvOrigin{
+			{Layer: "ci", Value: "true"},
+			{Layer: "os", Value: "false"},
+		}, newLayeredEnv().Origins("CI"))
+		assert.Nil(t, newLayeredEnv().Origins("UNKNOWN"))
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		l := newLayeredEnv()
+		assert.Equal(t, `PATH=/bin:/usr/local/bin (from dotenv)
+  dotenv: $PATH:/usr/local/bin
+  os: /bin`, l.Explain("PATH"))
+		assert.Equal(t, `TOKEN=*** (from secret)
+  secret: ***`, l.Explain("TOKEN"))
+		assert.Equal(t, "UNKNOWN is not set", l.Explain("UNKNOWN"))
+	})
+}
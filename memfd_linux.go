@@ -0,0 +1,27 @@
+//go:build linux
+
+package execx
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func newMemfdScriptFile(content string) (*scriptFile, error) {
+	fd, err := unix.MemfdCreate("execx", unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("%w: memfd create", err)
+	}
+	f := os.NewFile(uintptr(fd), "execx")
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("%w: memfd write", err)
+	}
+	return &scriptFile{
+		// other processes can open the file via procfs
+		path:  fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), fd),
+		memfd: f,
+	}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package execx
+
+import (
+	"errors"
+	"fmt"
+)
+
+func newMemfdScriptFile(_ string) (*scriptFile, error) {
+	return nil, fmt.Errorf("%w: memfd delivery", errors.ErrUnsupported)
+}
@@ -0,0 +1,122 @@
+package execx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidWords = errors.New("InvalidWords")
+)
+
+var (
+	quoteSafeRegex = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,-]+$`)
+)
+
+// Quote quotes s for POSIX shells so that the shell reads s as a single word as it is.
+//
+// s is returned as it is if it consists only of safe characters,
+// otherwise it is enclosed in single quotes:
+//
+//	it's -> 'it'\''s'
+func Quote(s string) string {
+	if quoteSafeRegex.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// QuoteArgs quotes each of args by [Quote] and joins them by spaces.
+func QuoteArgs(args ...string) string {
+	xs := make([]string, len(args))
+	for i, x := range args {
+		xs[i] = Quote(x)
+	}
+	return strings.Join(xs, " ")
+}
+
+// HeredocDelimiter returns a delimiter of a here-document that does not appear as a line in content.
+func HeredocDelimiter(content string) string {
+	lines := map[string]bool{}
+	for x := range strings.Lines(content) {
+		lines[strings.TrimRight(x, "\r\n")] = true
+	}
+	d := "EOF"
+	for i := 1; lines[d]; i++ {
+		d = "EOF_" + strconv.Itoa(i)
+	}
+	return d
+}
+
+// Heredoc returns a here-document of content without expansions, e.g.
+//
+//	<<'EOF'
+//	content
+//	EOF
+//
+// The delimiter is chosen by [HeredocDelimiter].
+func Heredoc(content string) string {
+	d := HeredocDelimiter(content)
+	content = strings.TrimSuffix(content, "\n")
+	return "<<'" + d + "'\n" + content + "\n" + d
+}
+
+// splitWords splits s into words like POSIX shells, handling quotes and backslashes.
+// Does not expand anything.
+func splitWords(s string) ([]string, error) {
+	var (
+		result []string
+		word   strings.Builder
+		inWord bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n':
+			if inWord {
+				result = append(result, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				word.WriteByte(s[i])
+			}
+		case '\'':
+			inWord = true
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, fmt.Errorf("%w: unterminated single quote", ErrInvalidWords)
+			}
+			word.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+		case '"':
+			inWord = true
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("%w: unterminated double quote", ErrInvalidWords)
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		result = append(result, word.String())
+	}
+	return result, nil
+}
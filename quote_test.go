@@ -0,0 +1,114 @@
+package execx_test
+
+import (
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuote(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		input string
+		want  string
+	}{
+		{
+			title: "empty",
+			input: "",
+			want:  "''",
+		},
+		{
+			title: "safe",
+			input: "path/to/file-1.txt",
+			want:  "path/to/file-1.txt",
+		},
+		{
+			title: "space",
+			input: "a b",
+			want:  "'a b'",
+		},
+		{
+			title: "single quote",
+			input: "it's",
+			want:  `'it'\''s'`,
+		},
+		{
+			title: "double quote",
+			input: `say "hi"`,
+			want:  `'say "hi"'`,
+		},
+		{
+			title: "assignment",
+			input: "A=b",
+			want:  "'A=b'",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, execx.Quote(tc.input))
+		})
+	}
+}
+
+func TestQuoteShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	args := []string{
+		"",
+		"plain",
+		"a b",
+		"it's",
+		`"double"`,
+		"$HOME ${HOME} $(echo x) `echo y`",
+		`back\slash\`,
+		"new\nline",
+		"*?[a]",
+		"; exit 1",
+		"A=b",
+	}
+	s := execx.NewScript(`for x in `+execx.QuoteArgs(args...)+`; do printf '%s|' "$x"; done`, "sh")
+	s.Delivery = execx.DeliverArg
+	var got string
+	assert.Nil(t, s.Runner(func(cmd *execx.Cmd) error {
+		r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(r.Stdout)
+		got = string(b)
+		return err
+	}))
+	assert.Equal(t, strings.Join(args, "|")+"|", got)
+}
+
+func TestHeredoc(t *testing.T) {
+	t.Run("delimiter", func(t *testing.T) {
+		assert.Equal(t, "EOF", execx.HeredocDelimiter("a\nb"))
+		assert.Equal(t, "EOF", execx.HeredocDelimiter("EOF1\n EOF"))
+		assert.Equal(t, "EOF_2", execx.HeredocDelimiter("EOF\nEOF_1\n"))
+	})
+
+	t.Run("shell", func(t *testing.T) {
+		if _, err := exec.LookPath("sh"); err != nil {
+			t.Skip("sh not found")
+		}
+		const content = "$HOME `x` \\ 'q' \"d\"\nEOF\n"
+		s := execx.NewScript("cat "+execx.Heredoc(content)+"\n", "sh")
+		var got string
+		assert.Nil(t, s.Runner(func(cmd *execx.Cmd) error {
+			r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+			if err != nil {
+				return err
+			}
+			b, err := io.ReadAll(r.Stdout)
+			got = string(b)
+			return err
+		}))
+		assert.Equal(t, content, got)
+	})
+}
@@ -2,37 +2,133 @@ package execx
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"sync"
+	"text/template"
 )
 
 // Script is an executable script, set of commands.
 type Script struct {
-	// Shell executes Content.
-	Shell []string
+	// Interpreter executes Content.
+	Interpreter
 	// Content is a set of commands.
 	Content string
 	Env     Env
 	// If KeepScriptFile is true, then do not regenerate script files,
 	// and not reflect changes in Content and Env when calling Runner.
+	// The script file is removed by [Script.Close].
 	KeepScriptFile bool
+	// If Shebang is true, then write a shebang line of Shell into the script file,
+	// and execute the script file directly.
+	// Only for [DeliverFile] and [DeliverMemfd].
+	Shebang bool
+	// Delivery is the way to pass the script to Shell, default is [DeliverFile].
+	Delivery ScriptDelivery
+	// FileDir is the directory of script files, default is [os.TempDir].
+	FileDir string
+	// FilePattern is the name of script files followed by Extension, see [os.CreateTemp].
+	// Default is [DefaultScriptFilePattern].
+	FilePattern string
+	// FileMode is the permission of script files, default is [DefaultScriptFileMode].
+	// Shebang requires the executable bits.
+	FileMode os.FileMode
+	// If Template is true, then Content is rendered by [text/template] with Data and Funcs
+	// instead of the expansion by Env.
+	// See [TemplateFuncs] for the default functions.
+	Template bool
+	// Data is passed to the template.
+	Data any
+	// Funcs are added to the template, overriding the default functions.
+	Funcs template.FuncMap
+	// If Cache is not nil, then script files are stored in the cache instead of FileDir,
+	// and reused while the expanded content and the interpreter are the same.
+	// KeepScriptFile is ignored. Only for [DeliverFile].
+	Cache *ScriptCache
+	// Args are passed to the script as positional parameters by [Script.Runner], e.g. $1 of sh.
+	// Args are not expanded.
+	Args []string
+	// If SourceMap is not nil, then line references to the script in stderr tokens are translated by [SourceMap.Translate].
+	// See [ExecutableTasks.IntoScript].
+	SourceMap *SourceMap
 
 	script *scriptFile
 	mux    *sync.Mutex
 }
 
 // NewScript creates a new [Script].
+//
+// If the shell is known, see [LookupInterpreter], the settings of the interpreter are used
+// except the preamble.
 func NewScript(content string, shell string, arg ...string) *Script {
+	interpreter, ok := LookupInterpreter(shell, arg...)
+	if ok {
+		interpreter.Preamble = ""
+	} else {
+		interpreter = Interpreter{
+			Shell: append([]string{shell}, arg...),
+		}
+	}
+	return NewScriptWithInterpreter(content, interpreter)
+}
+
+// NewScriptWithInterpreter creates a new [Script] executed by the interpreter.
+func NewScriptWithInterpreter(content string, interpreter Interpreter) *Script {
 	var mux sync.Mutex
+	interpreter.Shell = slices.Clone(interpreter.Shell)
+	interpreter.StdinArgs = slices.Clone(interpreter.StdinArgs)
+	interpreter.InlineArgs = slices.Clone(interpreter.InlineArgs)
+	interpreter.SyntaxCheck = slices.Clone(interpreter.SyntaxCheck)
 	return &Script{
-		Shell:   append([]string{shell}, arg...),
-		Content: content,
-		Env:     NewEnv(),
-		mux:     &mux,
+		Interpreter: interpreter,
+		Content:     content,
+		Env:         NewEnv(),
+		mux:         &mux,
 	}
 }
 
-// Close removes a temporary script file.
+const (
+	DefaultScriptFilePattern             = "execx*"
+	DefaultScriptFileMode    os.FileMode = 0755
+)
+
+// ScriptDelivery is the way to pass a script to the interpreter.
+type ScriptDelivery int
+
+const (
+	// DeliverFile writes the script into a temporary file and passes the path to Shell.
+	DeliverFile ScriptDelivery = iota
+	// DeliverStdin feeds the script on stdin of Shell with [Interpreter.StdinArgs].
+	// [Cmd.Stdin] is occupied by the script.
+	DeliverStdin
+	// DeliverArg passes the script as an argument of Shell with [Interpreter.InlineArgs], e.g. sh -c SCRIPT.
+	DeliverArg
+	// DeliverMemfd writes the script into an anonymous file created by memfd_create(2)
+	// and passes the path to Shell, no files are left behind.
+	// Available only on Linux.
+	DeliverMemfd
+)
+
+func (d ScriptDelivery) String() string {
+	switch d {
+	case DeliverFile:
+		return "file"
+	case DeliverStdin:
+		return "stdin"
+	case DeliverArg:
+		return "arg"
+	case DeliverMemfd:
+		return "memfd"
+	default:
+		return fmt.Sprintf("ScriptDelivery(%d)", int(d))
+	}
+}
+
+// Close releases the script file kept by KeepScriptFile.
+//
+// The file is removed after the running [Script.Runner] calls using it return.
 func (s *Script) Close() error {
 	s.mux.Lock()
 	defer s.mux.Unlock()
@@ -40,92 +136,285 @@ func (s *Script) Close() error {
 	if s.script != nil {
 		x := s.script
 		s.script = nil
-		return x.close()
+		return x.release()
 	}
 	return nil
 }
 
-func (s *Script) isExecutable() bool {
-	return s.script != nil && s.script.isExecutable()
+func (s *Script) useCache() bool {
+	return s.Cache != nil && s.Delivery == DeliverFile
 }
 
-func (s *Script) requireNewScript() bool {
-	return !s.isExecutable() || !s.KeepScriptFile
+func (s *Script) keepScript() bool {
+	return s.KeepScriptFile && !s.useCache()
 }
 
-func (s *Script) newScript() error {
+// acquireScript returns a script file for a [Script.Runner] call, the caller should release it.
+func (s *Script) acquireScript() (*scriptFile, error) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	if !s.requireNewScript() {
-		return nil
+	if s.keepScript() && s.script != nil && s.script.isExecutable() {
+		return s.script.acquire(), nil
 	}
 
-	content := s.Env.Expand(s.Content)
-	f, err := newScriptFile(content)
+	content, err := s.Render()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	s.script = f
-	return nil
+	var f *scriptFile
+	switch {
+	case s.Delivery == DeliverMemfd:
+		f, err = newMemfdScriptFile(content)
+	case s.useCache():
+		f, err = s.Cache.get(content, s.Shell, s.fileOptions())
+	default:
+		f, err = newScriptFile(content, s.fileOptions())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.keepScript() {
+		if s.script != nil {
+			_ = s.script.release()
+		}
+		s.script = f.acquire()
+	}
+	return f.acquire(), nil
 }
 
-func (s *Script) prepare() (*Cmd, error) {
-	if err := s.newScript(); err != nil {
-		return nil, err
+func (s *Script) fileOptions() scriptFileOptions {
+	opt := scriptFileOptions{
+		dir:       s.FileDir,
+		pattern:   s.FilePattern + s.Extension,
+		extension: s.Extension,
+		mode:      s.FileMode,
+	}
+	if s.FilePattern == "" {
+		opt.pattern = DefaultScriptFilePattern + s.Extension
+	}
+	if s.FileMode == 0 {
+		opt.mode = DefaultScriptFileMode
+	}
+	return opt
+}
+
+// Render returns the script passed to Shell.
+//
+// The script consists of a shebang line if Shebang is true, Preamble and Content
+// expanded by Env or rendered as a template if Template is true.
+func (s *Script) Render() (string, error) {
+	content, err := s.renderContent()
+	if err != nil {
+		return "", err
+	}
+	return s.renderHeader() + content, nil
+}
+
+// renderLocked is [Script.Render] under the lock for concurrent [Script.Runner] calls.
+func (s *Script) renderLocked() (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.Render()
+}
+
+// renderHeader returns the lines inserted before Content.
+func (s *Script) renderHeader() string {
+	var b strings.Builder
+	if s.Shebang && (s.Delivery == DeliverFile || s.Delivery == DeliverMemfd) {
+		b.WriteString(s.shebang() + "\n")
+	}
+	if s.Preamble != "" {
+		b.WriteString(strings.TrimSuffix(s.Preamble, "\n") + "\n")
+	}
+	return b.String()
+}
+
+func (s *Script) renderContent() (string, error) {
+	if !s.Template {
+		return s.Env.Expand(s.Content), nil
+	}
+	funcs := TemplateFuncs(s.Env)
+	maps.Copy(funcs, s.Funcs)
+	t, err := template.New("script").
+		Option("missingkey=error").
+		Funcs(funcs).
+		Parse(s.Content)
+	if err != nil {
+		return "", fmt.Errorf("%w: parse template", err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, s.Data); err != nil {
+		return "", fmt.Errorf("%w: execute template", err)
+	}
+	return b.String(), nil
+}
+
+// prepare creates a new [Cmd] and returns the script file used by it if exists, the caller should release it.
+func (s *Script) prepare(args []string) (*Cmd, *scriptFile, error) {
+	var (
+		cmd    *Cmd
+		script *scriptFile
+		// names of the script in the messages from the shell, $0 and so on
+		names = []string{s.Shell[0]}
+	)
+	switch s.Delivery {
+	case DeliverStdin:
+		content, err := s.renderLocked()
+		if err != nil {
+			return nil, nil, err
+		}
+		cmd = New(s.Shell[0], slices.Concat(s.Shell[1:], s.StdinArgs)...)
+		cmd.Stdin = strings.NewReader(content)
+		// bash reports errors in functions read from stdin as main
+		names = append(names, "main")
+	case DeliverArg:
+		content, err := s.renderLocked()
+		if err != nil {
+			return nil, nil, err
+		}
+		args := slices.Concat(s.Shell[1:], s.InlineArgs, []string{content})
+		// the script has been expanded already
+		cmd = New(s.Shell[0], args...)
+		cmd.rawArgs = []int{len(args)}
+		if s.InlineArg0 != "" {
+			cmd.Args = append(cmd.Args, s.InlineArg0)
+			names[0] = s.InlineArg0
+		}
+		// bash reports errors in functions defined by -c as environment
+		names = append(names, "environment")
+	default:
+		f, err := s.acquireScript()
+		if err != nil {
+			return nil, nil, err
+		}
+		script = f
+		names = []string{script.path}
+		if s.Shebang {
+			cmd = New(script.path)
+		} else {
+			cmd = New(s.Shell[0], slices.Concat(s.Shell[1:], []string{script.path})...)
+		}
+	}
+	for _, x := range args {
+		cmd.rawArgs = append(cmd.rawArgs, len(cmd.Args))
+		cmd.Args = append(cmd.Args, x)
 	}
-	cmd := New(s.Shell[0], append(s.Shell[1:], s.script.path)...)
 	cmd.Env.Merge(s.Env)
-	return cmd, nil
+	if s.SourceMap != nil {
+		m := s.SourceMap.shift(strings.Count(s.renderHeader(), "\n"))
+		cmd.stderrTransform = newSourceMapTranslator(m, names...).translateToken
+	}
+	return cmd, script, nil
 }
 
-// Runner creates a new [Cmd] and pass it to f.
+// Runner creates a new [Cmd] with Args and pass it to f.
+//
+// Runner is safe for concurrent use unless the fields are modified.
+// Without KeepScriptFile, each call uses its own script file removed after f returns.
+// With KeepScriptFile, calls share a script file until [Script.Close].
 func (s *Script) Runner(f func(*Cmd) error) error {
-	cmd, err := s.prepare()
+	return s.RunnerWithArgs(s.Args, f)
+}
+
+// RunnerWithArgs creates a new [Cmd] with args instead of Args and pass it to f.
+//
+// args are passed to the script as positional parameters as they are.
+func (s *Script) RunnerWithArgs(args []string, f func(*Cmd) error) error {
+	cmd, script, err := s.prepare(args)
 	if err != nil {
 		return fmt.Errorf("%w: prepare script file", err)
 	}
-
-	if !s.KeepScriptFile {
-		defer s.Close()
+	if script != nil {
+		defer script.release()
 	}
 	return f(cmd)
 }
 
 type scriptFile struct {
 	path string
+	mode os.FileMode
+	// cached is true if the script file is owned by [ScriptCache]
+	cached bool
+	// memfd is not nil if the script file is created by memfd_create(2)
+	memfd *os.File
+
+	mux sync.Mutex
+	// the number of the users, see acquire and release
+	refs int
 }
 
-func newScriptFile(content string) (*scriptFile, error) {
-	f, err := os.CreateTemp("", "execx")
+func (s *scriptFile) acquire() *scriptFile {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.refs++
+	return s
+}
+
+// release closes the script file if no one uses it.
+func (s *scriptFile) release() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	return s.close()
+}
+
+type scriptFileOptions struct {
+	dir       string
+	pattern   string
+	extension string
+	mode      os.FileMode
+}
+
+func newScriptFile(content string, opt scriptFileOptions) (*scriptFile, error) {
+	f, err := os.CreateTemp(opt.dir, opt.pattern)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	if _, err := f.WriteString(content); err != nil {
-		return nil, err
-	}
-	if err := os.Chmod(f.Name(), 0755); err != nil {
+	if err := writeScriptFile(f, content, opt.mode); err != nil {
+		_ = os.Remove(f.Name())
 		return nil, err
 	}
 	return &scriptFile{
 		path: f.Name(),
+		mode: opt.mode,
 	}, nil
 }
 
-func (s scriptFile) close() error {
+func writeScriptFile(f *os.File, content string, mode os.FileMode) error {
+	if _, err := f.WriteString(content); err != nil {
+		return err
+	}
+	return f.Chmod(mode)
+}
+
+func (s *scriptFile) close() error {
+	if s.cached {
+		return nil
+	}
+	if s.memfd != nil {
+		return s.memfd.Close()
+	}
 	return os.Remove(s.path)
 }
 
-func (s scriptFile) isExecutable() bool {
-	return isExecutable(s.path)
+func (s *scriptFile) isExecutable() bool {
+	if s.memfd != nil {
+		return true
+	}
+	return isExecutable(s.path, s.mode)
 }
 
-func isExecutable(path string) bool {
+// isExecutable returns true if path is a regular file with the mode.
+func isExecutable(path string, mode os.FileMode) bool {
 	f, err := os.Stat(path)
 	if err != nil {
 		return false
 	}
-	return !f.IsDir() && f.Mode() == 0755
+	return f.Mode().IsRegular() && f.Mode().Perm() == mode.Perm()
 }
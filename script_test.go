@@ -3,7 +3,13 @@ package execx_test
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/berquerant/execx"
@@ -268,3 +274,611 @@ line4
 		})
 	})
 }
+
+func requireCommand(t *testing.T, name string) {
+	t.Helper()
+	if _, err := exec.LookPath(name); err != nil {
+		t.Skipf("%s is not available", name)
+	}
+}
+
+func runScriptStdout(t *testing.T, script *execx.Script) (string, error) {
+	t.Helper()
+	var out string
+	err := script.Runner(func(cmd *execx.Cmd) error {
+		r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(r.Stdout)
+		if err != nil {
+			return err
+		}
+		out = string(b)
+		return nil
+	})
+	return out, err
+}
+
+func TestScriptInterpreter(t *testing.T) {
+	t.Run("lookup", func(t *testing.T) {
+		got, ok := execx.LookupInterpreter("/usr/bin/python3", "-u")
+		assert.True(t, ok)
+		assert.Equal(t, []string{"/usr/bin/python3", "-u"}, got.Shell)
+		assert.Equal(t, ".py", got.Extension)
+		_, ok = execx.LookupInterpreter("unknown")
+		assert.False(t, ok)
+	})
+
+	t.Run("NewScript sets extension only", func(t *testing.T) {
+		s := execx.NewScript(`echo "$0"`, "bash")
+		assert.Equal(t, ".sh", s.Extension)
+		assert.Equal(t, "", s.Preamble)
+		got, err := runScriptStdout(t, s)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasSuffix(got, ".sh\n"), got)
+	})
+
+	for _, tc := range []struct {
+		title       string
+		interpreter execx.Interpreter
+		content     string
+		want        string
+		err         bool
+	}{
+		{
+			title:       "sh",
+			interpreter: execx.InterpreterSh,
+			content:     `echo "sh $0"`,
+			want:        ".sh",
+		},
+		{
+			title:       "bash preamble",
+			interpreter: execx.InterpreterBash,
+			content: `false | true
+echo unreachable`,
+			err: true,
+		},
+		{
+			title:       "python",
+			interpreter: execx.InterpreterPython,
+			content: `import sys
+print(sys.argv[0])`,
+			want: ".py",
+		},
+		{
+			title:       "node",
+			interpreter: execx.InterpreterNode,
+			content:     `console.log(__filename)`,
+			want:        ".js",
+		},
+		{
+			title:       "perl preamble",
+			interpreter: execx.InterpreterPerl,
+			content:     `$x = 1; print "$0\n";`,
+			err:         true,
+		},
+		{
+			title:       "perl",
+			interpreter: execx.InterpreterPerl,
+			content:     `print "$0\n";`,
+			want:        ".pl",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			requireCommand(t, tc.interpreter.Shell[0])
+			for _, shebang := range []bool{false, true} {
+				t.Run(fmt.Sprintf("shebang=%v", shebang), func(t *testing.T) {
+					s := execx.NewScriptWithInterpreter(tc.content, tc.interpreter)
+					s.Shebang = shebang
+					got, err := runScriptStdout(t, s)
+					if tc.err {
+						assert.NotNil(t, err)
+						return
+					}
+					assert.Nil(t, err)
+					assert.True(t, strings.HasSuffix(got, tc.want+"\n"), got)
+				})
+			}
+		})
+	}
+
+	t.Run("shebang with args", func(t *testing.T) {
+		s := execx.NewScriptWithInterpreter(`echo "$-"`, execx.Interpreter{
+			Shell: []string{"sh", "-u"},
+		})
+		s.Shebang = true
+		got, err := runScriptStdout(t, s)
+		assert.Nil(t, err)
+		assert.Contains(t, got, "u")
+	})
+}
+
+func TestScriptDelivery(t *testing.T) {
+	for _, tc := range []struct {
+		title       string
+		interpreter execx.Interpreter
+		content     string
+		want        string
+	}{
+		{
+			title:       "sh",
+			interpreter: execx.InterpreterSh,
+			content:     `echo "$V $*"`,
+			want:        "v ARG1 ARG2\n",
+		},
+		{
+			title:       "bash",
+			interpreter: execx.InterpreterBash,
+			content:     `echo "$V $*"`,
+			want:        "v ARG1 ARG2\n",
+		},
+		{
+			title:       "python",
+			interpreter: execx.InterpreterPython,
+			content: `import os, sys
+print(os.environ["V"], *sys.argv[1:])`,
+			want: "v ARG1 ARG2\n",
+		},
+		{
+			title:       "perl",
+			interpreter: execx.InterpreterPerl,
+			content:     `print "$ENV{V} @ARGV\n";`,
+			want:        "v ARG1 ARG2\n",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			requireCommand(t, tc.interpreter.Shell[0])
+			for _, delivery := range []execx.ScriptDelivery{
+				execx.DeliverFile,
+				execx.DeliverStdin,
+				execx.DeliverArg,
+				execx.DeliverMemfd,
+			} {
+				t.Run(fmt.Sprint(delivery), func(t *testing.T) {
+					if delivery == execx.DeliverMemfd && runtime.GOOS != "linux" {
+						t.Skip("memfd is available only on linux")
+					}
+					tmpDir := t.TempDir()
+					t.Setenv("TMPDIR", tmpDir)
+
+					s := execx.NewScriptWithInterpreter(tc.content, tc.interpreter)
+					s.Delivery = delivery
+					s.Env.Set("V", "v")
+					var got string
+					err := s.Runner(func(cmd *execx.Cmd) error {
+						cmd.Args = append(cmd.Args, "ARG1", "ARG2")
+						r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+						if err != nil {
+							return err
+						}
+						b, err := io.ReadAll(r.Stdout)
+						got = string(b)
+						if delivery != execx.DeliverFile {
+							entries, err := os.ReadDir(tmpDir)
+							assert.Nil(t, err)
+							assert.Equal(t, 0, len(entries), "no temporary files")
+						}
+						return err
+					})
+					assert.Nil(t, err)
+					assert.Equal(t, tc.want, got)
+				})
+			}
+		})
+	}
+
+	t.Run("arg is not expanded twice", func(t *testing.T) {
+		s := execx.NewScript(`echo "$A"`, "sh")
+		s.Delivery = execx.DeliverArg
+		s.Env.SetWithMode("A", "$B", execx.SetRaw)
+		assert.Nil(t, s.Runner(func(cmd *execx.Cmd) error {
+			cmd.Env.Set("B", "b")
+			r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, []string{"sh", "-c", `echo "$B"`, "execx"}, r.ExpandedArgs)
+			assertReader(t, bytes.NewBufferString("b\n"), r.Stdout)
+			return nil
+		}))
+	})
+
+	t.Run("memfd shebang", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("memfd is available only on linux")
+		}
+		s := execx.NewScriptWithInterpreter(`echo shebang`, execx.InterpreterSh)
+		s.Delivery = execx.DeliverMemfd
+		s.Shebang = true
+		got, err := runScriptStdout(t, s)
+		assert.Nil(t, err)
+		assert.Equal(t, "shebang\n", got)
+	})
+}
+
+func TestScriptFile(t *testing.T) {
+	for _, tc := range []struct {
+		title    string
+		dir      bool
+		pattern  string
+		ext      string
+		mode     os.FileMode
+		wantName string
+		wantMode os.FileMode
+	}{
+		{
+			title:    "default",
+			wantName: "execx*.sh",
+			ext:      ".sh",
+			wantMode: 0755,
+		},
+		{
+			title:    "custom",
+			dir:      true,
+			pattern:  "deploy-*",
+			ext:      ".bash",
+			mode:     0700,
+			wantName: "deploy-*.bash",
+			wantMode: 0700,
+		},
+		{
+			title:    "not executable",
+			pattern:  "fixed",
+			mode:     0600,
+			wantName: "fixed*",
+			wantMode: 0600,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s := execx.NewScript(`echo "$0"`, "sh")
+			if tc.dir {
+				s.FileDir = t.TempDir()
+			}
+			s.FilePattern = tc.pattern
+			s.Extension = tc.ext
+			s.FileMode = tc.mode
+			s.KeepScriptFile = true
+			defer s.Close()
+
+			var paths []string
+			for range 2 {
+				got, err := runScriptStdout(t, s)
+				if !assert.Nil(t, err) {
+					return
+				}
+				path := strings.TrimSuffix(got, "\n")
+				paths = append(paths, path)
+
+				if tc.dir {
+					assert.Equal(t, s.FileDir, filepath.Dir(path))
+				}
+				ok, err := filepath.Match(tc.wantName, filepath.Base(path))
+				assert.Nil(t, err)
+				assert.True(t, ok, path)
+				info, err := os.Stat(path)
+				if assert.Nil(t, err) {
+					assert.Equal(t, tc.wantMode, info.Mode().Perm())
+				}
+			}
+			assert.Equal(t, paths[0], paths[1], "reuse the script file with the mode")
+		})
+	}
+
+	t.Run("shebang requires executable mode", func(t *testing.T) {
+		s := execx.NewScript(`echo shebang`, "sh")
+		s.Shebang = true
+		s.FileMode = 0600
+		_, err := runScriptStdout(t, s)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestScriptTemplate(t *testing.T) {
+	t.Run("loop and condition", func(t *testing.T) {
+		s := execx.NewScript(`{{range .Names}}echo {{quote .}}
+{{end}}{{if .Done}}echo done{{end}}`, "sh")
+		s.Template = true
+		s.Data = map[string]any{
+			"Names": []string{"a b", "it's"},
+			"Done":  true,
+		}
+		got, err := runScriptStdout(t, s)
+		assert.Nil(t, err)
+		assert.Equal(t, "a b\nit's\ndone\n", got)
+	})
+
+	t.Run("funcs", func(t *testing.T) {
+		s := execx.NewScript(`echo {{join "," .}}
+{{indent 2 "echo x"}}
+echo {{quoteArgs .}}
+echo {{env "V"}} {{upper "y"}}`, "sh")
+		s.Template = true
+		s.Data = []string{"a", "b"}
+		s.Env.Set("V", "v")
+		s.Funcs = map[string]any{
+			"upper": strings.ToUpper,
+		}
+		got, err := s.Render()
+		assert.Nil(t, err)
+		assert.Equal(t, `echo a,b
+  echo x
+echo a b
+echo v Y`, got)
+	})
+
+	t.Run("no env expansion", func(t *testing.T) {
+		s := execx.NewScript(`echo "${V}"`, "sh")
+		s.Template = true
+		s.Env.Set("V", "v")
+		got, err := s.Render()
+		assert.Nil(t, err)
+		assert.Equal(t, `echo "${V}"`, got)
+	})
+
+	for _, tc := range []struct {
+		title   string
+		content string
+	}{
+		{
+			title:   "parse error",
+			content: `{{if}}`,
+		},
+		{
+			title:   "missing key",
+			content: `echo {{.Missing}}`,
+		},
+		{
+			title:   "missing env",
+			content: `echo {{env "MISSING"}}`,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s := execx.NewScript(tc.content, "sh")
+			s.Template = true
+			s.Data = map[string]string{}
+			called := false
+			err := s.Runner(func(*execx.Cmd) error {
+				called = true
+				return nil
+			})
+			assert.NotNil(t, err)
+			assert.False(t, called)
+		})
+	}
+}
+
+func TestScriptArgs(t *testing.T) {
+	requireCommand(t, "sh")
+	args := []string{"a b", "$HOME", "it's", ""}
+	const want = "4\n[a b]\n[$HOME]\n[it's]\n[]\n"
+	const content = `echo $#
+for x in "$@"; do echo "[$x]"; done`
+
+	for _, tc := range []struct {
+		title    string
+		delivery execx.ScriptDelivery
+		shebang  bool
+	}{
+		{title: "file", delivery: execx.DeliverFile},
+		{title: "shebang", delivery: execx.DeliverFile, shebang: true},
+		{title: "stdin", delivery: execx.DeliverStdin},
+		{title: "arg", delivery: execx.DeliverArg},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			s := execx.NewScript(content, "sh")
+			s.Delivery = tc.delivery
+			s.Shebang = tc.shebang
+
+			var result *execx.Result
+			err := s.RunnerWithArgs(args, func(cmd *execx.Cmd) error {
+				r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+				result = r
+				return err
+			})
+			if !assert.Nil(t, err) {
+				return
+			}
+			b, err := io.ReadAll(result.Stdout)
+			assert.Nil(t, err)
+			assert.Equal(t, want, string(b))
+			assert.Equal(t, args, result.ExpandedArgs[len(result.ExpandedArgs)-len(args):])
+		})
+	}
+
+	t.Run("default args", func(t *testing.T) {
+		s := execx.NewScript(content, "sh")
+		s.Args = []string{"x"}
+		got, err := runScriptStdout(t, s)
+		assert.Nil(t, err)
+		assert.Equal(t, "1\n[x]\n", got)
+
+		s.Args = nil
+		got, err = runScriptStdout(t, s)
+		assert.Nil(t, err)
+		assert.Equal(t, "0\n", got)
+	})
+
+	t.Run("python", func(t *testing.T) {
+		requireCommand(t, "python3")
+		for _, d := range []execx.ScriptDelivery{execx.DeliverFile, execx.DeliverStdin, execx.DeliverArg} {
+			s := execx.NewScript(`import sys; print(sys.argv[1:])`, "python3")
+			s.Delivery = d
+			s.Args = []string{"a", "b c"}
+			got, err := runScriptStdout(t, s)
+			assert.Nil(t, err, d)
+			assert.Equal(t, "['a', 'b c']\n", got, d)
+		}
+	})
+}
+
+func TestScriptConcurrent(t *testing.T) {
+	requireCommand(t, "sh")
+
+	scriptPath := func(cmd *execx.Cmd) string {
+		return cmd.Args[len(cmd.Args)-1]
+	}
+	runEcho := func(cmd *execx.Cmd, want string) error {
+		r, err := cmd.Run(context.TODO(), execx.WithCaptureStdout(true))
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(r.Stdout)
+		if err != nil {
+			return err
+		}
+		if got := string(b); got != want {
+			return fmt.Errorf("got %q want %q", got, want)
+		}
+		return nil
+	}
+	assertEmptyDir := func(t *testing.T, dir string) {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Empty(t, entries)
+	}
+
+	t.Run("deliveries", func(t *testing.T) {
+		for _, d := range []execx.ScriptDelivery{execx.DeliverFile, execx.DeliverStdin, execx.DeliverArg} {
+			t.Run(d.String(), func(t *testing.T) {
+				s := execx.NewScript(`echo ${X:=v} $X $Y`, "sh")
+				s.Delivery = d
+				s.FileDir = t.TempDir()
+				s.Env.Set("Y", "y")
+
+				var eg errgroup.Group
+				for range 8 {
+					eg.Go(func() error {
+						return s.Runner(func(cmd *execx.Cmd) error {
+							return runEcho(cmd, "v v y\n")
+						})
+					})
+				}
+				assert.Nil(t, eg.Wait())
+				_, ok := s.Env.Get("X")
+				assert.False(t, ok)
+				assertEmptyDir(t, s.FileDir)
+			})
+		}
+	})
+
+	t.Run("private files", func(t *testing.T) {
+		dir := t.TempDir()
+		s := execx.NewScript("sleep 0.05; echo ok", "sh")
+		s.FileDir = dir
+
+		var (
+			eg    errgroup.Group
+			paths = make([]string, 16)
+		)
+		for i := range paths {
+			eg.Go(func() error {
+				return s.Runner(func(cmd *execx.Cmd) error {
+					paths[i] = scriptPath(cmd)
+					return runEcho(cmd, "ok\n")
+				})
+			})
+		}
+		assert.Nil(t, eg.Wait())
+		seen := map[string]bool{}
+		for _, p := range paths {
+			assert.False(t, seen[p], "script files should not be shared")
+			seen[p] = true
+		}
+		assertEmptyDir(t, dir)
+	})
+
+	t.Run("shared file outlives close", func(t *testing.T) {
+		dir := t.TempDir()
+		s := execx.NewScript("echo ok", "sh")
+		s.FileDir = dir
+		s.KeepScriptFile = true
+
+		var (
+			eg       errgroup.Group
+			started  = make(chan string)
+			closed   = make(chan struct{})
+			runnerCh = make(chan error, 1)
+		)
+		go func() {
+			runnerCh <- s.Runner(func(cmd *execx.Cmd) error {
+				started <- scriptPath(cmd)
+				<-closed
+				return runEcho(cmd, "ok\n")
+			})
+		}()
+		path := <-started
+		for range 8 {
+			eg.Go(func() error {
+				return s.Runner(func(cmd *execx.Cmd) error {
+					if p := scriptPath(cmd); p != path {
+						return fmt.Errorf("got %s want shared %s", p, path)
+					}
+					return runEcho(cmd, "ok\n")
+				})
+			})
+		}
+		assert.Nil(t, eg.Wait())
+
+		assert.Nil(t, s.Close())
+		_, err := os.Stat(path)
+		assert.Nil(t, err, "the running script file should not be removed")
+		close(closed)
+		assert.Nil(t, <-runnerCh)
+		assertEmptyDir(t, dir)
+	})
+
+	t.Run("regenerate while running", func(t *testing.T) {
+		dir := t.TempDir()
+		s := execx.NewScript("echo ok", "sh")
+		s.FileDir = dir
+		s.KeepScriptFile = true
+
+		var (
+			started  = make(chan string)
+			resume   = make(chan struct{})
+			runnerCh = make(chan error, 1)
+		)
+		go func() {
+			runnerCh <- s.Runner(func(cmd *execx.Cmd) error {
+				started <- scriptPath(cmd)
+				<-resume
+				return runEcho(cmd, "ok\n")
+			})
+		}()
+		path := <-started
+		// the kept file becomes not executable, the next call regenerates it
+		assert.Nil(t, os.Chmod(path, 0600))
+		assert.Nil(t, s.Runner(func(cmd *execx.Cmd) error {
+			assert.NotEqual(t, path, scriptPath(cmd))
+			return runEcho(cmd, "ok\n")
+		}))
+		_, err := os.Stat(path)
+		assert.Nil(t, err, "the running script file should not be removed")
+		assert.Nil(t, os.Chmod(path, 0755))
+		close(resume)
+		assert.Nil(t, <-runnerCh)
+		assert.Nil(t, s.Close())
+		assertEmptyDir(t, dir)
+	})
+}
+
+func TestScriptShellParameters(t *testing.T) {
+	requireCommand(t, "sh")
+	s := execx.NewScript(`x=local
+echo "hello ${1:-world} ${x:-none} [${y:+alt}] ${X:=assigned} $X"`, "sh")
+
+	s.Args = []string{"alice"}
+	got, err := runScriptStdout(t, s)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello alice local [] assigned assigned\n", got)
+
+	s.Args = nil
+	got, err = runScriptStdout(t, s)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world local [] assigned assigned\n", got)
+
+	_, ok := s.Env.Get("X")
+	assert.False(t, ok, "expansion should not change env")
+}
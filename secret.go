@@ -0,0 +1,119 @@
+package execx
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// RedactedValue replaces secret values in outputs.
+const RedactedValue = "***"
+
+// SetSecret sets the variable and marks it as secret.
+//
+// Secret variables are passed to commands as they are,
+// but their values are redacted in [Env.String], [Result.String], [ExecutableTasks.String]
+// and tokens passed to consumers of [Cmd.Run].
+func (e Env) SetSecret(key, value string) {
+	e.Set(key, value)
+	e.vars.secrets[key] = true
+}
+
+// MarkSecret marks the existing variables as secret.
+func (e Env) MarkSecret(key ...string) {
+	for _, k := range key {
+		if _, ok := e.Get(k); ok {
+			e.vars.secrets[k] = true
+		}
+	}
+}
+
+// IsSecret returns true if the variable is secret.
+func (e Env) IsSecret(key string) bool {
+	if e.vars == nil {
+		return false
+	}
+	return e.vars.secrets[key]
+}
+
+// SecretValues returns the non-empty values of the secret variables,
+// including the expanded ones.
+func (e Env) SecretValues() []string {
+	var result []string
+	for k, v := range e.All() {
+		if !e.IsSecret(k) {
+			continue
+		}
+		for _, x := range []string{v, e.Expand(v)} {
+			if x != "" && !slices.Contains(result, x) {
+				result = append(result, x)
+			}
+		}
+	}
+	return result
+}
+
+// Redact replaces the values of the secret variables in s with [RedactedValue].
+func (e Env) Redact(s string) string {
+	return newRedactor(e.SecretValues()).redact(s)
+}
+
+// RedactedSlice is [Env.IntoSlice] but the values of the secret variables are redacted.
+func (e Env) RedactedSlice() []string {
+	result := make([]string, 0, e.Len())
+	for k, v := range e.All() {
+		if e.Mode(k) == SetLazy {
+			v = e.Expand(v)
+		}
+		if e.IsSecret(k) {
+			v = RedactedValue
+		} else {
+			v = e.Redact(v)
+		}
+		result = append(result, k+"="+v)
+	}
+	return result
+}
+
+// String returns the variables in os.Environ format, separated by newlines.
+// The values of the secret variables are redacted.
+func (e Env) String() string {
+	return strings.Join(e.RedactedSlice(), "\n")
+}
+
+type redactor struct {
+	replacer *strings.Replacer
+}
+
+func newRedactor(secrets []string) *redactor {
+	if len(secrets) == 0 {
+		return &redactor{}
+	}
+	// replace longer secrets first
+	xs := slices.Clone(secrets)
+	slices.SortStableFunc(xs, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	oldnew := make([]string, 0, len(xs)*2)
+	for _, x := range xs {
+		oldnew = append(oldnew, x, RedactedValue)
+	}
+	return &redactor{
+		replacer: strings.NewReplacer(oldnew...),
+	}
+}
+
+func (r *redactor) enabled() bool {
+	return r.replacer != nil
+}
+
+func (r *redactor) redact(s string) string {
+	if !r.enabled() {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+func (r *redactor) redactToken(t Token) Token {
+	return token(r.redact(t.String()))
+}
@@ -0,0 +1,114 @@
+package execx_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	newEnv := func() execx.Env {
+		e := execx.NewEnv()
+		e.Set("USER", "alice")
+		e.SetSecret("TOKEN", "s3cr3t")
+		e.Set("AUTH", "Bearer $TOKEN")
+		return e
+	}
+
+	t.Run("flags", func(t *testing.T) {
+		e := newEnv()
+		assert.True(t, e.IsSecret("TOKEN"))
+		assert.False(t, e.IsSecret("USER"))
+		assert.False(t, e.IsSecret("UNKNOWN"))
+
+		e.MarkSecret("USER", "UNKNOWN")
+		assert.True(t, e.IsSecret("USER"))
+		assert.False(t, e.IsSecret("UNKNOWN"))
+
+		e.Unset("USER")
+		e.Set("USER", "bob")
+		assert.False(t, e.IsSecret("USER"), "unset clears the flag")
+	})
+
+	t.Run("propagate", func(t *testing.T) {
+		e := execx.NewEnv()
+		e.Merge(newEnv())
+		assert.True(t, e.IsSecret("TOKEN"))
+		assert.True(t, newEnv().Allow("TOKEN").IsSecret("TOKEN"))
+	})
+
+	t.Run("slices", func(t *testing.T) {
+		e := newEnv()
+		assert.Equal(t, []string{"USER=alice", "TOKEN=s3cr3t", "AUTH=Bearer $TOKEN"}, e.IntoSlice())
+		assert.Equal(t, []string{"USER=alice", "TOKEN=***", "AUTH=Bearer $TOKEN"}, e.RedactedSlice())
+		assert.Equal(t, "USER=alice\nTOKEN=***\nAUTH=Bearer $TOKEN", e.String())
+		assert.Equal(t, "USER=alice\nTOKEN=***\nAUTH=Bearer $TOKEN", fmt.Sprint(e))
+	})
+
+	t.Run("redact", func(t *testing.T) {
+		e := newEnv()
+		e.SetSecret("LONG", "s3cr3t-long")
+		e.SetSecret("EMPTY", "")
+		assert.Equal(t, "token=*** long=*** user=alice", e.Redact("token=s3cr3t long=s3cr3t-long user=alice"))
+		assert.Equal(t, "nothing", execx.NewEnv().Redact("nothing"))
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		cmd := execx.New("sh", "-c", `echo "user=$USER token=$TOKEN"; echo "$AUTH" >&2`, "-", "$TOKEN")
+		cmd.Env = newEnv()
+		var (
+			stdout, stderr bytes.Buffer
+			gotOut, gotErr []string
+		)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		r, err := cmd.Run(
+			context.TODO(),
+			execx.WithStdoutConsumer(func(x execx.Token) {
+				gotOut = append(gotOut, x.String())
+			}),
+			execx.WithStderrConsumer(func(x execx.Token) {
+				gotErr = append(gotErr, x.String())
+			}),
+		)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"user=alice token=***"}, gotOut)
+		assert.Equal(t, []string{"Bearer ***"}, gotErr)
+		assert.Equal(t, "user=alice token=s3cr3t\n", stdout.String(), "raw output")
+		assert.Equal(t, "Bearer s3cr3t\n", stderr.String(), "raw output")
+
+		assert.Equal(t, "s3cr3t", r.ExpandedArgs[4])
+		assert.Equal(t, "***", r.RedactedArgs()[4])
+		assert.NotContains(t, r.String(), "s3cr3t")
+	})
+
+	t.Run("ExecutableTasks", func(t *testing.T) {
+		tasks := execx.NewExecutableTasks(
+			execx.NewTasks().Add(execx.NewTask("f", `echo "$AUTH"`)),
+			newEnv(),
+			"f",
+		)
+		assert.Equal(t, `USER=alice
+TOKEN='***'
+AUTH='Bearer $TOKEN'
+f() {
+echo "$AUTH"
+}
+f
+`, tasks.String())
+
+		var got bytes.Buffer
+		assert.Nil(t, tasks.IntoScript("sh").Runner(func(cmd *execx.Cmd) error {
+			cmd.Stdout = &got
+			_, err := cmd.Run(context.TODO())
+			return err
+		}))
+		assert.Equal(t, "Bearer s3cr3t\n", got.String())
+	})
+}
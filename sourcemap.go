@@ -0,0 +1,98 @@
+package execx
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SourceLocation is a location in a task of a generated script.
+type SourceLocation struct {
+	Task string
+	// Line is the line number in the script of the task.
+	Line int
+}
+
+func (l SourceLocation) String() string {
+	return fmt.Sprintf("task %s line %d", l.Task, l.Line)
+}
+
+// SourceMap maps lines of a generated script to the tasks.
+type SourceMap struct {
+	// locations of the lines, nil if the line is not in any task
+	lines []*SourceLocation
+}
+
+func (m *SourceMap) add(loc *SourceLocation) {
+	m.lines = append(m.lines, loc)
+}
+
+// shift returns a new [SourceMap] for the script with n lines inserted at the head.
+func (m *SourceMap) shift(n int) *SourceMap {
+	return &SourceMap{
+		lines: append(make([]*SourceLocation, n), m.lines...),
+	}
+}
+
+// Lookup returns the location of the line of the generated script, 1-based.
+//
+// Returns false if the line is not in any task, e.g. entrypoints.
+func (m *SourceMap) Lookup(line int) (SourceLocation, bool) {
+	if m == nil || line < 1 || line > len(m.lines) || m.lines[line-1] == nil {
+		return SourceLocation{}, false
+	}
+	return *m.lines[line-1], true
+}
+
+// Translate appends the locations in the tasks to the line references to the script in s, e.g.
+//
+//	/tmp/script.sh: line 37: foo: command not found
+//
+// becomes
+//
+//	/tmp/script.sh: line 37 (task f line 2): foo: command not found
+//
+// script is the name of the generated script in the messages, e.g. the path of the script file or $0.
+// References to other files, e.g. main.go:2: from a compiler, are left as they are.
+func (m *SourceMap) Translate(script, s string) string {
+	return newSourceMapTranslator(m, script).translate(s)
+}
+
+type sourceMapTranslator struct {
+	m  *SourceMap
+	re *regexp.Regexp
+}
+
+func newSourceMapTranslator(m *SourceMap, scripts ...string) *sourceMapTranslator {
+	xs := make([]string, len(scripts))
+	for i, x := range scripts {
+		xs[i] = regexp.QuoteMeta(x)
+	}
+	return &sourceMapTranslator{
+		m: m,
+		// SCRIPT: line 37: (bash), SCRIPT: 37: (dash), SCRIPT:37: (zsh)
+		re: regexp.MustCompile(`^(?:` + strings.Join(xs, "|") + `):(?: line |\s?)(\d+):`),
+	}
+}
+
+func (t *sourceMapTranslator) translate(s string) string {
+	loc := t.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return s
+	}
+	n := s[loc[2]:loc[3]]
+	x, err := strconv.Atoi(n)
+	if err != nil {
+		return s
+	}
+	l, ok := t.m.Lookup(x)
+	if !ok {
+		return s
+	}
+	return fmt.Sprintf("%s (%s)%s", s[:loc[3]], l, s[loc[3]:])
+}
+
+func (t *sourceMapTranslator) translateToken(x Token) Token {
+	return token(t.translate(x.String()))
+}
@@ -0,0 +1,174 @@
+package execx_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSourceMapTasks() *execx.ExecutableTasks {
+	return execx.NewExecutableTasks(
+		execx.NewTasks().
+			Add(execx.NewTask("f", "echo f1\necho f2")).
+			Add(execx.NewTask("g", "echo g1\nundefined_command_in_g\necho 'foo.go:2: from a child' >&2")),
+		execx.NewEnv(),
+		"f",
+		"g",
+	)
+}
+
+func TestSourceMap(t *testing.T) {
+	m := newSourceMapTasks().SourceMap()
+
+	t.Run("lookup", func(t *testing.T) {
+		for _, tc := range []struct {
+			line int
+			want execx.SourceLocation
+			ok   bool
+		}{
+			{line: 0},
+			{line: 1},
+			{line: 2, want: execx.SourceLocation{Task: "f", Line: 1}, ok: true},
+			{line: 3, want: execx.SourceLocation{Task: "f", Line: 2}, ok: true},
+			{line: 4},
+			{line: 5},
+			{line: 7, want: execx.SourceLocation{Task: "g", Line: 2}, ok: true},
+			{line: 9},
+			{line: 100},
+		} {
+			got, ok := m.Lookup(tc.line)
+			assert.Equal(t, tc.ok, ok, tc.line)
+			assert.Equal(t, tc.want, got, tc.line)
+		}
+	})
+
+	t.Run("translate", func(t *testing.T) {
+		const script = "/tmp/execx1.sh"
+		for _, tc := range []struct {
+			title  string
+			script string
+			input  string
+			want   string
+		}{
+			{
+				title: "bash",
+				input: "/tmp/execx1.sh: line 7: undefined_command_in_g: command not found",
+				want:  "/tmp/execx1.sh: line 7 (task g line 2): undefined_command_in_g: command not found",
+			},
+			{
+				title: "dash",
+				input: "/tmp/execx1.sh: 3: undefined: not found\n",
+				want:  "/tmp/execx1.sh: 3 (task f line 2): undefined: not found\n",
+			},
+			{
+				title: "zsh",
+				input: "/tmp/execx1.sh:7: command not found: undefined_command_in_g",
+				want:  "/tmp/execx1.sh:7 (task g line 2): command not found: undefined_command_in_g",
+			},
+			{
+				title:  "$0",
+				script: "execx",
+				input:  "execx: 7: undefined_command_in_g: not found",
+				want:   "execx: 7 (task g line 2): undefined_command_in_g: not found",
+			},
+			{
+				title: "not in tasks",
+				input: "/tmp/execx1.sh: line 10: f: error",
+				want:  "/tmp/execx1.sh: line 10: f: error",
+			},
+			{
+				title: "other file",
+				input: "main.go:2: unused variable x",
+				want:  "main.go:2: unused variable x",
+			},
+			{
+				title: "other script",
+				input: "/tmp/other.sh: line 7: error",
+				want:  "/tmp/other.sh: line 7: error",
+			},
+			{
+				title: "line in message",
+				input: "error at line 7",
+				want:  "error at line 7",
+			},
+			{
+				title: "no references",
+				input: "error",
+				want:  "error",
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				name := script
+				if tc.script != "" {
+					name = tc.script
+				}
+				assert.Equal(t, tc.want, m.Translate(name, tc.input))
+			})
+		}
+	})
+}
+
+func TestExecutableTasksSourceMap(t *testing.T) {
+	requireCommand(t, "bash")
+
+	t.Run("stderr", func(t *testing.T) {
+		for _, tc := range []struct {
+			title    string
+			shell    string
+			delivery execx.ScriptDelivery
+			shebang  bool
+		}{
+			{title: "bash file", shell: "bash", delivery: execx.DeliverFile},
+			{title: "bash shebang", shell: "bash", delivery: execx.DeliverFile, shebang: true},
+			{title: "bash stdin", shell: "bash", delivery: execx.DeliverStdin},
+			{title: "bash arg", shell: "bash", delivery: execx.DeliverArg},
+			{title: "sh file", shell: "sh", delivery: execx.DeliverFile},
+			{title: "sh arg", shell: "sh", delivery: execx.DeliverArg},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				requireCommand(t, tc.shell)
+				s := newSourceMapTasks().IntoScript(tc.shell)
+				s.Delivery = tc.delivery
+				s.Shebang = tc.shebang
+				var stderr []string
+				err := s.Runner(func(cmd *execx.Cmd) error {
+					_, err := cmd.Run(
+						context.TODO(),
+						execx.WithStderrConsumer(func(x execx.Token) {
+							stderr = append(stderr, strings.TrimSpace(x.String()))
+						}),
+					)
+					return err
+				})
+				assert.Nil(t, err)
+				if assert.Len(t, stderr, 2) {
+					assert.Contains(t, stderr[0], "(task g line 2): undefined_command_in_g")
+					assert.Equal(t, "foo.go:2: from a child", stderr[1], "output of the child should not be translated")
+				}
+			})
+		}
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		s := execx.NewExecutableTasks(
+			execx.NewTasks().
+				Add(execx.NewTask("f", "echo f1")).
+				Add(execx.NewTask("g", "echo g1\nif then")),
+			execx.NewEnv(),
+			"f",
+		).IntoScript("bash")
+		err := s.Validate(context.TODO())
+		var serr *execx.SyntaxError
+		if !assert.True(t, errors.As(err, &serr)) {
+			return
+		}
+		if assert.NotEmpty(t, serr.Lines) {
+			assert.Equal(t, &execx.SourceLocation{Task: "g", Line: 2}, serr.Lines[0].Source)
+		}
+		assert.Contains(t, err.Error(), "task g line 2")
+	})
+}
@@ -1,29 +1,272 @@
 package execx
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+)
 
-	"github.com/berquerant/execx/internal"
+var (
+	ErrTaskNotFound     = errors.New("TaskNotFound")
+	ErrTaskCycle        = errors.New("TaskCycle")
+	ErrInvalidTaskParam = errors.New("InvalidTaskParam")
 )
 
+// TaskParam is a named parameter of a task.
+//
+// The task accepts the parameter as an argument name=value before the other arguments,
+// and reads it as a local variable.
+// The name should not be a variable of the Env of the tasks or the task,
+// because the variables are expanded in the script before the task reads the arguments.
+type TaskParam struct {
+	Name    string
+	Default string
+	// If Required is true, then the argument with a non-empty value is required.
+	// A required parameter cannot have Default.
+	Required bool
+}
+
 // Task is a named script function.
 type Task struct {
 	Name   string
 	Script string
+	// Description is the summary of the task, not used in scripts.
+	Description string
+	// Deps are the names of the tasks to be executed before this task.
+	Deps []string
+	// Env overrides the variables in the task.
+	Env Env
+	// Dir is the working directory of the task.
+	Dir string
+	// Interpreter executes Script instead of the shell of the tasks if not nil.
+	// Script receives the arguments of the task.
+	Interpreter *Interpreter
+	// Params are the named parameters of the task.
+	// If Interpreter is not nil, they are passed as environment variables.
+	Params []TaskParam
+}
+
+// CheckArgs returns [ErrInvalidTaskParam] if the arguments do not satisfy Params.
+//
+// Arguments in the form of name=value are the parameters, the others are positional arguments.
+// If the task has no Params, all the arguments are positional.
+func (t Task) CheckArgs(args ...string) error {
+	if len(t.Params) == 0 {
+		return nil
+	}
+	params := map[string]TaskParam{}
+	for _, p := range t.Params {
+		if !isName(p.Name) {
+			return fmt.Errorf("%w: task %s: invalid parameter name %q", ErrInvalidTaskParam, t.Name, p.Name)
+		}
+		if _, ok := params[p.Name]; ok {
+			return fmt.Errorf("%w: task %s: duplicated parameter %s", ErrInvalidTaskParam, t.Name, p.Name)
+		}
+		if p.Required && p.Default != "" {
+			return fmt.Errorf("%w: task %s: required parameter %s has a default", ErrInvalidTaskParam, t.Name, p.Name)
+		}
+		params[p.Name] = p
+	}
+
+	given := map[string]bool{}
+	for _, x := range args {
+		name, value, ok := strings.Cut(x, "=")
+		if !ok || !isName(name) {
+			// the rest are positional
+			break
+		}
+		if _, ok := params[name]; !ok {
+			return fmt.Errorf("%w: task %s: unknown parameter %s", ErrInvalidTaskParam, t.Name, name)
+		}
+		given[name] = value != ""
+	}
+	for _, p := range t.Params {
+		if p.Required && !given[p.Name] {
+			return fmt.Errorf("%w: task %s: parameter %s is required", ErrInvalidTaskParam, t.Name, p.Name)
+		}
+	}
+	return nil
+}
+
+// checkParamEnv returns [ErrInvalidTaskParam] if a name of Params is a variable of env or Env.
+func (t Task) checkParamEnv(env Env) error {
+	for _, p := range t.Params {
+		for _, e := range []Env{env, t.Env} {
+			if _, ok := e.Get(p.Name); ok {
+				return fmt.Errorf("%w: task %s: parameter %s conflicts with the variable", ErrInvalidTaskParam, t.Name, p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func isName(s string) bool {
+	return s != "" && scanParamName(s, false) == s && isNameHead(s[0])
 }
 
-func NewTask(name, script string) *Task {
+func NewTask(name, script string, deps ...string) *Task {
 	return &Task{
 		Name:   name,
 		Script: script,
+		Deps:   deps,
 	}
 }
 
 func (t Task) String() string {
-	return fmt.Sprintf(`%s() {
-%s
-}`, t.Name, t.Script)
+	var b strings.Builder
+	for i, x := range t.lines(true, Env{}) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(x.line)
+	}
+	return b.String()
+}
+
+// paramLines returns the lines to read Params from the arguments.
+func (t Task) paramLines() []string {
+	if len(t.Params) == 0 {
+		return nil
+	}
+	var result []string
+	for _, p := range t.Params {
+		result = append(result, "local "+p.Name+"="+Quote(p.Default))
+	}
+	result = append(result,
+		"while [ $# -gt 0 ]; do",
+		`case "$1" in`,
+	)
+	for _, p := range t.Params {
+		result = append(result, fmt.Sprintf(`%[1]s=*) %[1]s="${1#%[1]s=}" ;;`, p.Name))
+	}
+	result = append(result,
+		"*) break ;;",
+		"esac",
+		"shift",
+		"done",
+	)
+	for _, p := range t.Params {
+		if p.Required {
+			result = append(result, fmt.Sprintf(`: "${%[1]s:?parameter %[1]s is required}"`, p.Name))
+		}
+	}
+	if t.Interpreter != nil {
+		names := make([]string, len(t.Params))
+		for i, p := range t.Params {
+			names[i] = p.Name
+		}
+		result = append(result, "export "+strings.Join(names, " "))
+	}
+	return result
+}
+
+// isolated returns true if the task runs in a subshell.
+func (t Task) isolated() bool {
+	return t.Env.Len() > 0 || t.Dir != "" || t.Interpreter != nil
+}
+
+type taskLine struct {
+	line string
+	loc  *SourceLocation
+}
+
+// lines returns the definition of the function of the task.
+//
+// The task with Env, Dir or Interpreter becomes a function running in a subshell.
+// If dry is true, the values of the secret variables are redacted,
+// otherwise Script is expanded by base and Env because the script of the tasks is expanded by base.
+func (t Task) lines(dry bool, base Env) []taskLine {
+	script := t.Script
+	if !dry && t.Env.Len() > 0 {
+		env := base.Clone()
+		env.Merge(t.Env)
+		script = env.Expand(script)
+	}
+	var (
+		result []taskLine
+		add    = func(loc *SourceLocation, line string) {
+			result = append(result, taskLine{
+				line: line,
+				loc:  loc,
+			})
+		}
+		addScript = func(script string, offset int) {
+			for i, line := range strings.Split(script, "\n") {
+				var loc *SourceLocation
+				if i >= offset {
+					loc = &SourceLocation{
+						Task: t.Name,
+						Line: i - offset + 1,
+					}
+				}
+				add(loc, line)
+			}
+		}
+	)
+
+	addParams := func() {
+		for _, x := range t.paramLines() {
+			add(nil, x)
+		}
+	}
+
+	if !t.isolated() {
+		add(nil, t.Name+"() {")
+		addParams()
+		addScript(script, 0)
+		add(nil, "}")
+		return result
+	}
+
+	add(nil, t.Name+"() (")
+	if t.Dir != "" {
+		add(nil, "cd -- "+Quote(t.Dir)+" || exit 1")
+	}
+	for _, x := range t.Env.IntoSlice() {
+		k, v, _ := strings.Cut(x, "=")
+		switch {
+		case dry && t.Env.IsSecret(k):
+			v = RedactedValue
+		case dry:
+			v = t.Env.Redact(v)
+		}
+		add(nil, "export "+k+"="+Quote(v))
+	}
+	addParams()
+	if t.Interpreter == nil {
+		addScript(script, 0)
+		add(nil, ")")
+		return result
+	}
+
+	var (
+		interpreter = t.Interpreter
+		offset      int
+	)
+	if interpreter.Preamble != "" {
+		preamble := strings.TrimSuffix(interpreter.Preamble, "\n")
+		script = preamble + "\n" + script
+		offset = strings.Count(preamble, "\n") + 1
+	}
+	// command avoids calling the function of the same name as the interpreter
+	if len(interpreter.InlineArgs) > 0 {
+		args := slices.Concat(interpreter.Shell, interpreter.InlineArgs)
+		rest := []string{}
+		if interpreter.InlineArg0 != "" {
+			rest = append(rest, Quote(interpreter.InlineArg0))
+		}
+		rest = append(rest, `"$@"`)
+		addScript("command "+QuoteArgs(args...)+" "+Quote(script)+" "+strings.Join(rest, " "), offset)
+	} else {
+		args := slices.Concat(interpreter.Shell, interpreter.StdinArgs)
+		delim := HeredocDelimiter(script)
+		add(nil, "command "+QuoteArgs(args...)+` "$@" <<'`+delim+"'")
+		addScript(strings.TrimSuffix(script, "\n"), offset)
+		add(nil, delim)
+	}
+	add(nil, ")")
+	return result
 }
 
 type Tasks []*Task
@@ -36,6 +279,76 @@ func (t Tasks) Add(task *Task) Tasks {
 	return append(t, task)
 }
 
+// Get returns the task by the name.
+func (t Tasks) Get(name string) (*Task, bool) {
+	for _, x := range t {
+		if x.Name == name {
+			return x, true
+		}
+	}
+	return nil, false
+}
+
+func (t Tasks) has(name string) bool {
+	_, ok := t.Get(name)
+	return ok
+}
+
+// Resolve returns the tasks and their dependencies in order of execution, each task appears once.
+//
+// Returns [ErrTaskNotFound] if a task does not exist, [ErrTaskCycle] if tasks depend on each other circularly.
+func (t Tasks) Resolve(name ...string) (Tasks, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	var (
+		result Tasks
+		state  = map[string]int{}
+		stack  []string
+		visit  func(name, requiredBy string) error
+	)
+	visit = func(name, requiredBy string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			i := 0
+			for stack[i] != name {
+				i++
+			}
+			cycle := append(append([]string{}, stack[i:]...), name)
+			return fmt.Errorf("%w: %s", ErrTaskCycle, strings.Join(cycle, " -> "))
+		}
+		task, ok := t.Get(name)
+		if !ok {
+			if requiredBy != "" {
+				return fmt.Errorf("%w: %s required by %s", ErrTaskNotFound, name, requiredBy)
+			}
+			return fmt.Errorf("%w: %s", ErrTaskNotFound, name)
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, d := range task.Deps {
+			if err := visit(d, name); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		result = append(result, task)
+		return nil
+	}
+
+	for _, x := range name {
+		if err := visit(x, ""); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 func (t Tasks) String() string {
 	ss := make([]string, len(t))
 	for i, x := range t {
@@ -45,9 +358,11 @@ func (t Tasks) String() string {
 }
 
 type ExecutableTasks struct {
-	Tasks      Tasks
-	Env        Env
-	Entrypoint []string // task names to execute
+	Tasks Tasks
+	Env   Env
+	// Entrypoint are the commands to execute, e.g. task names and task names with arguments.
+	// The dependencies of the tasks are executed before them, see [ExecutableTasks.Plan].
+	Entrypoint []string
 }
 
 func NewExecutableTasks(
@@ -62,35 +377,147 @@ func NewExecutableTasks(
 	}
 }
 
+// IntoScript creates a new [Script] executing the tasks.
+//
+// Line references in the stderr tokens of the script are translated by [ExecutableTasks.SourceMap].
 func (t ExecutableTasks) IntoScript(shell string, arg ...string) *Script {
+	content, sourceMap := t.generate(false)
+	return t.newScript(content, sourceMap, shell, arg...)
+}
+
+func (t ExecutableTasks) newScript(content string, sourceMap *SourceMap, shell string, arg ...string) *Script {
 	s := NewScript(
-		t.asString(false),
+		content,
 		shell,
 		arg...,
 	)
 	s.Env = t.Env
+	s.SourceMap = sourceMap
 	return s
 }
 
+// Plan returns Entrypoint with the dependencies of the tasks inserted before them.
+//
+// An entrypoint whose first word is a task name calls the task, the dependencies run once per invocation.
+// The other entrypoints are shell commands left as they are.
+// An entrypoint of just a task name is skipped if the task has already run.
+func (t ExecutableTasks) Plan() ([]string, error) {
+	if err := t.checkParamEnv(); err != nil {
+		return nil, err
+	}
+	var (
+		result []string
+		ran    = map[string]bool{}
+	)
+	for _, entry := range t.Entrypoint {
+		fields, err := splitWords(entry)
+		if err != nil {
+			// other commands are left to the shell, e.g. comments with quotes
+			if words := strings.Fields(entry); len(words) == 0 || !t.Tasks.has(words[0]) {
+				result = append(result, entry)
+				continue
+			}
+			return nil, fmt.Errorf("%w: entrypoint %s", err, entry)
+		}
+		if len(fields) == 0 {
+			result = append(result, entry)
+			continue
+		}
+		task, ok := t.Tasks.Get(fields[0])
+		if !ok {
+			result = append(result, entry)
+			continue
+		}
+		if err := task.CheckArgs(fields[1:]...); err != nil {
+			return nil, fmt.Errorf("%w: entrypoint %s", err, entry)
+		}
+		deps, err := t.Tasks.Resolve(task.Deps...)
+		if err != nil {
+			return nil, fmt.Errorf("%w: entrypoint %s", err, entry)
+		}
+		for _, d := range deps {
+			if err := d.CheckArgs(); err != nil {
+				return nil, fmt.Errorf("%w: dependency of entrypoint %s", err, entry)
+			}
+			if !ran[d.Name] {
+				ran[d.Name] = true
+				result = append(result, d.Name)
+			}
+		}
+		if len(fields) == 1 && ran[task.Name] {
+			continue
+		}
+		ran[task.Name] = true
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// checkParamEnv checks the parameters of all the tasks, the script defines all of them.
+func (t ExecutableTasks) checkParamEnv() error {
+	for _, task := range t.Tasks {
+		if err := task.checkParamEnv(t.Env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the script with the variables quoted by [Quote].
+// The values of the secret variables are redacted.
 func (t ExecutableTasks) String() string {
-	return t.asString(true)
+	s, _ := t.generate(true)
+	return s
 }
 
-func (t ExecutableTasks) asString(dry bool) string {
+// SourceMap returns the map from the lines of the script of [ExecutableTasks.IntoScript] to the tasks.
+func (t ExecutableTasks) SourceMap() *SourceMap {
+	_, m := t.generate(false)
+	return m
+}
+
+func (t ExecutableTasks) generate(dry bool) (string, *SourceMap) {
+	plan, err := t.Plan()
+	if err != nil {
+		// fail when the script runs
+		plan = []string{fmt.Sprintf("echo %s >&2", Quote(err.Error())), "exit 1"}
+	}
+	return t.render(dry, plan)
+}
+
+// render returns the script executing the commands after the definitions of the tasks.
+func (t ExecutableTasks) render(dry bool, commands []string) (string, *SourceMap) {
 	var (
 		b strings.Builder
-		w = func(f string, a ...any) {
+		m SourceMap
+		w = func(loc *SourceLocation, f string, a ...any) {
 			b.WriteString(fmt.Sprintf(f, a...) + "\n")
+			m.add(loc)
 		}
 	)
 
 	if dry {
-		for k, v := range t.Env {
-			w(`%s="%s"`, k, internal.EscapeQuote(v))
+		for k, v := range t.Env.All() {
+			switch {
+			case t.Env.IsSecret(k):
+				v = RedactedValue
+			case t.Env.Mode(k) == SetLazy:
+				v = t.Env.Redact(t.Env.Expand(v))
+			default:
+				v = t.Env.Redact(v)
+			}
+			w(nil, "%s=%s", k, Quote(v))
+		}
+	}
+	if len(t.Tasks) == 0 {
+		w(nil, "")
+	}
+	for _, task := range t.Tasks {
+		for _, x := range task.lines(dry, t.Env) {
+			w(x.loc, "%s", x.line)
 		}
 	}
-	w("%s", t.Tasks.String())
-	w("%s", strings.Join(t.Entrypoint, "\n"))
+	w(nil, "%s", strings.Join(commands, "\n"))
 
-	return b.String()
+	return b.String(), &m
 }
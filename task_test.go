@@ -3,6 +3,8 @@ package execx_test
 import (
 	"context"
 	"io"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/berquerant/execx"
@@ -103,6 +105,26 @@ got x
 	}
 }
 
+func TestExecutableTasksString(t *testing.T) {
+	tasks := execx.NewExecutableTasks(
+		execx.NewTasks().
+			Add(execx.NewTask("f", `echo "$Z $A"`)),
+		execx.EnvFromSlice([]string{"Z=z", "A=a", "M=m"}),
+		"f",
+	)
+	const want = `Z=z
+A=a
+M=m
+f() {
+echo "$Z $A"
+}
+f
+`
+	for range 8 {
+		assert.Equal(t, want, tasks.String())
+	}
+}
+
 func TestTasks(t *testing.T) {
 	for _, tc := range []struct {
 		title string
@@ -142,3 +164,441 @@ echo end
 		})
 	}
 }
+
+func newDependentTasks() execx.Tasks {
+	return execx.NewTasks().
+		Add(execx.NewTask("build", `echo build`, "generate", "deps")).
+		Add(execx.NewTask("generate", `echo generate`, "deps")).
+		Add(execx.NewTask("deps", `echo deps`)).
+		Add(execx.NewTask("test", `echo "test $*"`, "build")).
+		Add(execx.NewTask("lint", `echo lint`, "deps"))
+}
+
+func TestTasksResolve(t *testing.T) {
+	taskNames := func(tasks execx.Tasks) []string {
+		r := make([]string, len(tasks))
+		for i, x := range tasks {
+			r[i] = x.Name
+		}
+		return r
+	}
+
+	for _, tc := range []struct {
+		title string
+		tasks execx.Tasks
+		names []string
+		want  []string
+		err   error
+	}{
+		{
+			title: "no dependencies",
+			tasks: newDependentTasks(),
+			names: []string{"deps"},
+			want:  []string{"deps"},
+		},
+		{
+			title: "dependencies first",
+			tasks: newDependentTasks(),
+			names: []string{"test"},
+			want:  []string{"deps", "generate", "build", "test"},
+		},
+		{
+			title: "shared dependencies once",
+			tasks: newDependentTasks(),
+			names: []string{"lint", "test", "lint"},
+			want:  []string{"deps", "lint", "generate", "build", "test"},
+		},
+		{
+			title: "not found",
+			tasks: newDependentTasks(),
+			names: []string{"deploy"},
+			err:   execx.ErrTaskNotFound,
+		},
+		{
+			title: "dependency not found",
+			tasks: execx.NewTasks().Add(execx.NewTask("a", "", "x")),
+			names: []string{"a"},
+			err:   execx.ErrTaskNotFound,
+		},
+		{
+			title: "cycle",
+			tasks: execx.NewTasks().
+				Add(execx.NewTask("a", "", "b")).
+				Add(execx.NewTask("b", "", "c")).
+				Add(execx.NewTask("c", "", "a")),
+			names: []string{"a"},
+			err:   execx.ErrTaskCycle,
+		},
+		{
+			title: "self",
+			tasks: execx.NewTasks().Add(execx.NewTask("a", "", "a")),
+			names: []string{"a"},
+			err:   execx.ErrTaskCycle,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := tc.tasks.Resolve(tc.names...)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, taskNames(got))
+		})
+	}
+
+	t.Run("cycle message", func(t *testing.T) {
+		_, err := execx.NewTasks().
+			Add(execx.NewTask("a", "", "b")).
+			Add(execx.NewTask("b", "", "a")).
+			Resolve("a")
+		assert.EqualError(t, err, "TaskCycle: a -> b -> a")
+	})
+}
+
+func TestExecutableTasksPlan(t *testing.T) {
+	for _, tc := range []struct {
+		title      string
+		entrypoint []string
+		want       []string
+		wantOutput string
+	}{
+		{
+			title:      "dependencies",
+			entrypoint: []string{"build"},
+			want:       []string{"deps", "generate", "build"},
+			wantOutput: "deps\ngenerate\nbuild\n",
+		},
+		{
+			title:      "once per invocation",
+			entrypoint: []string{"build", "lint", "test x", "build"},
+			want:       []string{"deps", "generate", "build", "lint", "test x"},
+			wantOutput: "deps\ngenerate\nbuild\nlint\ntest x\n",
+		},
+		{
+			title:      "tasks with arguments are not skipped",
+			entrypoint: []string{"test a", "test b"},
+			want:       []string{"deps", "generate", "build", "test a", "test b"},
+			wantOutput: "deps\ngenerate\nbuild\ntest a\ntest b\n",
+		},
+		{
+			title:      "commands",
+			entrypoint: []string{"echo start", "deps"},
+			want:       []string{"echo start", "deps"},
+			wantOutput: "start\ndeps\n",
+		},
+		{
+			title:      "commands not split into words",
+			entrypoint: []string{"lint", "echo done # it's ok"},
+			want:       []string{"deps", "lint", "echo done # it's ok"},
+			wantOutput: "deps\nlint\ndone\n",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			tasks := execx.NewExecutableTasks(newDependentTasks(), execx.NewEnv(), tc.entrypoint...)
+			got, err := tasks.Plan()
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+
+			out, err := runScriptStdout(t, tasks.IntoScript("sh"))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantOutput, out)
+		})
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		tasks := execx.NewExecutableTasks(
+			execx.NewTasks().
+				Add(execx.NewTask("a", "echo a", "b")).
+				Add(execx.NewTask("b", "echo b", "a")),
+			execx.NewEnv(),
+			"a",
+		)
+		_, err := tasks.Plan()
+		assert.ErrorIs(t, err, execx.ErrTaskCycle)
+		_, err = runScriptStdout(t, tasks.IntoScript("sh"))
+		assert.NotNil(t, err, "the script should fail")
+	})
+}
+
+func TestTaskIsolation(t *testing.T) {
+	requireCommand(t, "sh")
+	dir := t.TempDir()
+
+	withEnv := execx.NewTask("with_env", `echo "$A $B"`)
+	withEnv.Env = execx.EnvFromSlice([]string{"A=task a"})
+	withDir := execx.NewTask("with_dir", `pwd`)
+	withDir.Dir = dir
+	python := execx.NewTask("python", `import sys
+print("python", sys.argv[1:])`)
+	python.Interpreter = &execx.InterpreterPython
+	perl := execx.NewTask("perl", `print "perl @ARGV\n";`)
+	perl.Interpreter = &execx.Interpreter{
+		Shell:     []string{"perl"},
+		StdinArgs: []string{"-"},
+		Preamble:  "use strict;",
+	}
+
+	tasks := execx.NewTasks().
+		Add(withEnv).
+		Add(withDir).
+		Add(python).
+		Add(perl).
+		Add(execx.NewTask("plain", `echo "$A $B"; pwd`)).
+		Add(execx.NewTask("call", `python "it's" b; perl x y`))
+
+	t.Run("definition", func(t *testing.T) {
+		assert.Equal(t, `with_env() (
+export A='task a'
+echo "$A $B"
+)`, withEnv.String())
+		assert.Equal(t, `with_dir() (
+cd -- `+execx.Quote(dir)+` || exit 1
+pwd
+)`, withDir.String())
+	})
+
+	t.Run("run", func(t *testing.T) {
+		requireCommand(t, "python3")
+		requireCommand(t, "perl")
+		wd, err := os.Getwd()
+		assert.Nil(t, err)
+		got, err := runScriptStdout(t, execx.NewExecutableTasks(
+			tasks,
+			execx.EnvFromSlice([]string{"A=base a", "B=base b"}),
+			"with_env",
+			"with_dir",
+			"plain",
+			"call",
+		).IntoScript("sh"))
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Join([]string{
+			"task a base b",
+			dir,
+			"base a base b",
+			wd,
+			`python ["it's", 'b']`,
+			"perl x y",
+		}, "\n")+"\n", got)
+	})
+
+	t.Run("source map", func(t *testing.T) {
+		m := execx.NewExecutableTasks(tasks, execx.NewEnv()).SourceMap()
+		var got []execx.SourceLocation
+		for i := 1; i < 100; i++ {
+			if loc, ok := m.Lookup(i); ok && (loc.Task == "python" || loc.Task == "perl") {
+				got = append(got, loc)
+			}
+		}
+		assert.Equal(t, []execx.SourceLocation{
+			{Task: "python", Line: 1},
+			{Task: "python", Line: 2},
+			{Task: "perl", Line: 1},
+		}, got)
+	})
+
+	t.Run("secret", func(t *testing.T) {
+		task := execx.NewTask("f", `echo "$TOKEN"`)
+		task.Env = execx.NewEnv()
+		task.Env.SetSecret("TOKEN", "s3cr3t")
+		s := execx.NewExecutableTasks(execx.NewTasks().Add(task), execx.NewEnv(), "f").String()
+		assert.NotContains(t, s, "s3cr3t")
+		assert.Contains(t, s, "export TOKEN='***'")
+	})
+}
+
+func TestTaskParams(t *testing.T) {
+	newDeploy := func() *execx.Task {
+		task := execx.NewTask("deploy", `echo "deploy $env $region msg=$msg args=$*"`)
+		task.Params = []execx.TaskParam{
+			{Name: "env", Default: "dev"},
+			{Name: "region", Required: true},
+			{Name: "msg"},
+		}
+		return task
+	}
+
+	t.Run("check args", func(t *testing.T) {
+		for _, tc := range []struct {
+			title string
+			args  []string
+			err   bool
+		}{
+			{title: "required", args: []string{"region=us"}},
+			{title: "all", args: []string{"env=prod", "region=us", "msg=hi"}},
+			{title: "positional", args: []string{"region=us", "x", "y=z"}},
+			{title: "missing required", args: []string{"env=prod"}, err: true},
+			{title: "empty required", args: []string{"region="}, err: true},
+			{title: "unknown", args: []string{"region=us", "zone=a"}, err: true},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				err := newDeploy().CheckArgs(tc.args...)
+				if tc.err {
+					assert.ErrorIs(t, err, execx.ErrInvalidTaskParam)
+				} else {
+					assert.Nil(t, err)
+				}
+			})
+		}
+
+		t.Run("no params", func(t *testing.T) {
+			assert.Nil(t, execx.NewTask("f", "").CheckArgs("k=v", "--opt=1"))
+		})
+
+		t.Run("required with default", func(t *testing.T) {
+			task := execx.NewTask("f", "")
+			task.Params = []execx.TaskParam{{Name: "p", Default: "d", Required: true}}
+			assert.ErrorIs(t, task.CheckArgs("p=v"), execx.ErrInvalidTaskParam)
+		})
+
+		t.Run("invalid name", func(t *testing.T) {
+			task := execx.NewTask("f", "")
+			task.Params = []execx.TaskParam{{Name: "a-b"}}
+			assert.ErrorIs(t, task.CheckArgs(), execx.ErrInvalidTaskParam)
+		})
+	})
+
+	t.Run("run", func(t *testing.T) {
+		requireCommand(t, "sh")
+		for _, tc := range []struct {
+			title      string
+			entrypoint []string
+			want       string
+			err        error
+		}{
+			{
+				title:      "defaults",
+				entrypoint: []string{"deploy region=us"},
+				want:       "deploy dev us msg= args=\n",
+			},
+			{
+				title:      "quoted values and positional args",
+				entrypoint: []string{`deploy env=prod region=eu msg='hello world' a b`},
+				want:       "deploy prod eu msg=hello world args=a b\n",
+			},
+			{
+				title:      "called by other tasks",
+				entrypoint: []string{"release"},
+				want:       "deploy staging ap msg= args=\n",
+			},
+			{
+				title:      "missing required",
+				entrypoint: []string{"deploy env=prod"},
+				err:        execx.ErrInvalidTaskParam,
+			},
+			{
+				title:      "unterminated quote",
+				entrypoint: []string{"deploy region='us"},
+				err:        execx.ErrInvalidWords,
+			},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				tasks := execx.NewExecutableTasks(
+					execx.NewTasks().
+						Add(newDeploy()).
+						Add(execx.NewTask("release", `deploy env=staging region=ap`)),
+					execx.NewEnv(),
+					tc.entrypoint...,
+				)
+				_, err := tasks.Plan()
+				if tc.err != nil {
+					assert.ErrorIs(t, err, tc.err)
+					return
+				}
+				assert.Nil(t, err)
+				got, err := runScriptStdout(t, tasks.IntoScript("sh"))
+				assert.Nil(t, err)
+				assert.Equal(t, tc.want, got)
+			})
+		}
+	})
+
+	t.Run("required at runtime", func(t *testing.T) {
+		requireCommand(t, "sh")
+		_, err := runScriptStdout(t, execx.NewExecutableTasks(
+			execx.NewTasks().
+				Add(newDeploy()).
+				Add(execx.NewTask("release", `deploy env=staging`)),
+			execx.NewEnv(),
+			"release",
+		).IntoScript("sh"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("interpreter", func(t *testing.T) {
+		requireCommand(t, "python3")
+		task := execx.NewTask("greet", `import os, sys
+print(os.environ["name"], sys.argv[1:])`)
+		task.Interpreter = &execx.InterpreterPython
+		task.Params = []execx.TaskParam{{Name: "name", Default: "world"}}
+		got, err := runScriptStdout(t, execx.NewExecutableTasks(
+			execx.NewTasks().Add(task),
+			execx.NewEnv(),
+			"greet",
+			"greet name=you x",
+		).IntoScript("sh"))
+		assert.Nil(t, err)
+		assert.Equal(t, "world []\nyou ['x']\n", got)
+	})
+
+	t.Run("no params", func(t *testing.T) {
+		requireCommand(t, "sh")
+		got, err := runScriptStdout(t, execx.NewExecutableTasks(
+			execx.NewTasks().Add(execx.NewTask("greet", `echo "$@"`)),
+			execx.NewEnv(),
+			"greet a=b",
+			"greet k=v --opt=1 x",
+		).IntoScript("sh"))
+		assert.Nil(t, err)
+		assert.Equal(t, "a=b\nk=v --opt=1 x\n", got)
+	})
+
+	t.Run("conflicts with env", func(t *testing.T) {
+		requireCommand(t, "sh")
+		newTask := func() *execx.Task {
+			task := execx.NewTask("d", `echo "target=$target"`)
+			task.Params = []execx.TaskParam{{Name: "target", Default: "dev"}}
+			return task
+		}
+		taskEnv := newTask()
+		taskEnv.Env = execx.EnvFromPairs("target", "fromtask")
+		for _, tc := range []struct {
+			title string
+			task  *execx.Task
+			env   execx.Env
+		}{
+			{title: "tasks env", task: newTask(), env: execx.EnvFromPairs("target", "fromenv")},
+			{title: "task env", task: taskEnv, env: execx.NewEnv()},
+		} {
+			t.Run(tc.title, func(t *testing.T) {
+				tasks := execx.NewExecutableTasks(execx.NewTasks().Add(tc.task), tc.env, "d target=prod")
+				_, err := tasks.Plan()
+				assert.ErrorIs(t, err, execx.ErrInvalidTaskParam)
+				assert.ErrorContains(t, err, "parameter target conflicts")
+				_, err = runScriptStdout(t, tasks.IntoScript("sh"))
+				assert.NotNil(t, err, "the script should fail")
+
+				_, err = execx.NewTaskRunner(execx.NewTasks().Add(tc.task), tc.env).Run(context.TODO(), "d")
+				assert.ErrorIs(t, err, execx.ErrInvalidTaskParam)
+			})
+		}
+	})
+
+	t.Run("runner requires arguments", func(t *testing.T) {
+		_, err := execx.NewTaskRunner(execx.NewTasks().Add(newDeploy()), execx.NewEnv()).Run(context.TODO(), "deploy")
+		assert.ErrorIs(t, err, execx.ErrInvalidTaskParam)
+	})
+}
+
+func TestTaskShellParameters(t *testing.T) {
+	requireCommand(t, "sh")
+	got, err := runScriptStdout(t, execx.NewExecutableTasks(
+		execx.NewTasks().
+			Add(execx.NewTask("greet", `echo "hello ${1:-world}"`)),
+		execx.NewEnv(),
+		"greet",
+		"greet alice",
+	).IntoScript("sh"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello world\nhello alice\n", got)
+}
@@ -0,0 +1,348 @@
+package execx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidTaskFile   = errors.New("InvalidTaskFile")
+	ErrUnsupportedFormat = errors.New("UnsupportedFormat")
+)
+
+// TaskFileFormat is the format of task files.
+type TaskFileFormat string
+
+const (
+	TaskFileYAML TaskFileFormat = "yaml"
+	TaskFileJSON TaskFileFormat = "json"
+	TaskFileTOML TaskFileFormat = "toml"
+)
+
+// TaskFileFormatFromPath returns the format by the extension of the path.
+func TaskFileFormatFromPath(path string) (TaskFileFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return TaskFileYAML, nil
+	case ".json":
+		return TaskFileJSON, nil
+	case ".toml":
+		return TaskFileTOML, nil
+	default:
+		return "", fmt.Errorf("%w: task file %s", ErrUnsupportedFormat, path)
+	}
+}
+
+type taskFile struct {
+	Env        map[string]string `json:"env" yaml:"env" toml:"env"`
+	Entrypoint []string          `json:"entrypoint" yaml:"entrypoint" toml:"entrypoint"`
+	Tasks      []taskFileTask    `json:"tasks" yaml:"tasks" toml:"tasks"`
+	// names of Env in the file, nil if the format is unordered
+	envOrder []string
+}
+
+type taskFileTask struct {
+	Name        string            `json:"name" yaml:"name" toml:"name"`
+	Description string            `json:"description" yaml:"description" toml:"description"`
+	Script      string            `json:"script" yaml:"script" toml:"script"`
+	Deps        []string          `json:"deps" yaml:"deps" toml:"deps"`
+	Env         map[string]string `json:"env" yaml:"env" toml:"env"`
+	Dir         string            `json:"dir" yaml:"dir" toml:"dir"`
+	Interpreter string            `json:"interpreter" yaml:"interpreter" toml:"interpreter"`
+	Params      []taskFileParam   `json:"params" yaml:"params" toml:"params"`
+	// names of Env in the file, nil if the format is unordered
+	envOrder []string
+}
+
+type taskFileParam struct {
+	Name     string `json:"name" yaml:"name" toml:"name"`
+	Default  string `json:"default" yaml:"default" toml:"default"`
+	Required bool   `json:"required" yaml:"required" toml:"required"`
+}
+
+// LoadTaskFile creates a new [ExecutableTasks] from a task file, the format is detected by the extension.
+//
+// See [ReadTaskFile] for the schema.
+func LoadTaskFile(path string) (*ExecutableTasks, error) {
+	format, err := TaskFileFormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: open task file %s", err, path)
+	}
+	defer f.Close()
+	tasks, err := ReadTaskFile(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: load task file %s", err, path)
+	}
+	return tasks, nil
+}
+
+// ReadTaskFile creates a new [ExecutableTasks] from a task file.
+//
+//	env:                      # variables, set in order
+//	  TARGET: bin/app
+//	entrypoint:               # commands to execute
+//	  - deploy env=prod
+//	tasks:
+//	  - name: build           # required, unique
+//	    description: build the app
+//	    script: go build -o $TARGET
+//	    deps: [generate]      # names of the tasks
+//	    env: {CGO_ENABLED: "0"}
+//	    dir: src
+//	    interpreter: python3  # command, see [LookupInterpreter]
+//	    params:
+//	      - name: env         # required, unique in the task, not a name of env
+//	        default: dev
+//	        required: false   # true cannot have default
+//
+// JSON and TOML have the same structure.
+// The variables of env are set in order of the file in YAML, in order of the names in JSON and TOML,
+// because their objects and tables are unordered.
+// Unknown fields are errors. Returns the errors of the validation joined, the messages have the paths of the fields.
+func ReadTaskFile(r io.Reader, format TaskFileFormat) (*ExecutableTasks, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read task file", err)
+	}
+	var x taskFile
+	if err := decodeTaskFile(b, format, &x); err != nil {
+		return nil, err
+	}
+	return x.build()
+}
+
+func decodeTaskFile(b []byte, format TaskFileFormat, v *taskFile) error {
+	switch format {
+	case TaskFileYAML:
+		d := yaml.NewDecoder(bytes.NewReader(b))
+		d.KnownFields(true)
+		if err := d.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: %w", ErrInvalidTaskFile, err)
+		}
+		if err := orderYAMLTaskFileEnv(b, v); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTaskFile, err)
+		}
+	case TaskFileJSON:
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		if err := d.Decode(v); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTaskFile, err)
+		}
+	case TaskFileTOML:
+		m, err := toml.NewDecoder(bytes.NewReader(b)).Decode(v)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTaskFile, err)
+		}
+		if keys := m.Undecoded(); len(keys) > 0 {
+			return fmt.Errorf("%w: unknown field %s", ErrInvalidTaskFile, keys[0])
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return nil
+}
+
+// orderYAMLTaskFileEnv records the order of the variables, which is lost by decoding into maps.
+func orderYAMLTaskFileEnv(b []byte, v *taskFile) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	v.envOrder = yamlMappingKeys(yamlMappingValue(root, "env"))
+	if tasks := yamlMappingValue(root, "tasks"); tasks != nil && tasks.Kind == yaml.SequenceNode {
+		for i, x := range tasks.Content {
+			if i < len(v.Tasks) {
+				v.Tasks[i].envOrder = yamlMappingKeys(yamlMappingValue(x, "env"))
+			}
+		}
+	}
+	return nil
+}
+
+func yamlResolveAlias(n *yaml.Node) *yaml.Node {
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+func yamlMappingValue(n *yaml.Node, key string) *yaml.Node {
+	n = yamlResolveAlias(n)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return yamlResolveAlias(n.Content[i+1])
+		}
+	}
+	return nil
+}
+
+func yamlMappingKeys(n *yaml.Node) []string {
+	n = yamlResolveAlias(n)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	keys := make([]string, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keys = append(keys, n.Content[i].Value)
+	}
+	return keys
+}
+
+// taskFileEnvKeys returns the names of the variables in order of the file,
+// the rest of them, e.g. merged by YAML merge keys, follow in order of the names.
+func taskFileEnvKeys(m map[string]string, order []string) []string {
+	keys := make([]string, 0, len(m))
+	seen := map[string]bool{}
+	for _, k := range order {
+		if _, ok := m[k]; ok && !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+	var rest []string
+	for k := range m {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	slices.Sort(rest)
+	return append(keys, rest...)
+}
+
+func envFromTaskFile(m map[string]string, order []string) Env {
+	env := NewEnv()
+	for _, k := range taskFileEnvKeys(m, order) {
+		env.Set(k, m[k])
+	}
+	return env
+}
+
+func (f taskFile) build() (*ExecutableTasks, error) {
+	var (
+		errs   []error
+		errorf = func(format string, a ...any) {
+			errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidTaskFile}, a...)...))
+		}
+		tasks = NewTasks()
+		index = map[string]int{}
+	)
+
+	for _, k := range taskFileEnvKeys(f.Env, f.envOrder) {
+		if !isName(k) {
+			errorf("env.%s: invalid name", k)
+		}
+	}
+	for i, x := range f.Tasks {
+		path := fmt.Sprintf("tasks[%d]", i)
+		switch j, ok := index[x.Name]; {
+		case x.Name == "":
+			errorf("%s.name: required", path)
+		case !isName(x.Name):
+			errorf("%s.name: invalid name %q", path, x.Name)
+		case ok:
+			errorf("%s.name: duplicated name %s, see tasks[%d]", path, x.Name, j)
+		default:
+			index[x.Name] = i
+		}
+		for _, k := range taskFileEnvKeys(x.Env, x.envOrder) {
+			if !isName(k) {
+				errorf("%s.env.%s: invalid name", path, k)
+			}
+		}
+
+		task := NewTask(x.Name, x.Script, x.Deps...)
+		task.Description = x.Description
+		task.Dir = x.Dir
+		if len(x.Env) > 0 {
+			task.Env = envFromTaskFile(x.Env, x.envOrder)
+		}
+		if x.Interpreter != "" {
+			shell, err := splitWords(x.Interpreter)
+			switch {
+			case err != nil:
+				errorf("%s.interpreter: %s", path, err)
+			case len(shell) == 0:
+				errorf("%s.interpreter: empty", path)
+			default:
+				interpreter, ok := LookupInterpreter(shell[0], shell[1:]...)
+				if !ok {
+					interpreter = Interpreter{
+						Shell: shell,
+					}
+				}
+				task.Interpreter = &interpreter
+			}
+		}
+		seen := map[string]bool{}
+		for j, p := range x.Params {
+			ppath := fmt.Sprintf("%s.params[%d]", path, j)
+			switch {
+			case p.Name == "":
+				errorf("%s.name: required", ppath)
+			case !isName(p.Name):
+				errorf("%s.name: invalid name %q", ppath, p.Name)
+			case seen[p.Name]:
+				errorf("%s.name: duplicated name %s", ppath, p.Name)
+			}
+			if _, ok := f.Env[p.Name]; ok {
+				errorf("%s.name: %s conflicts with env.%s", ppath, p.Name, p.Name)
+			}
+			if _, ok := x.Env[p.Name]; ok {
+				errorf("%s.name: %s conflicts with %s.env.%s", ppath, p.Name, path, p.Name)
+			}
+			if p.Required && p.Default != "" {
+				errorf("%s: required parameter with default", ppath)
+			}
+			seen[p.Name] = true
+			task.Params = append(task.Params, TaskParam{
+				Name:     p.Name,
+				Default:  p.Default,
+				Required: p.Required,
+			})
+		}
+		tasks = tasks.Add(task)
+	}
+
+	for i, x := range f.Tasks {
+		for j, d := range x.Deps {
+			if _, ok := index[d]; !ok {
+				errorf("tasks[%d].deps[%d]: unknown task %s", i, j, d)
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	for i, x := range tasks {
+		if _, err := tasks.Resolve(x.Name); err != nil {
+			return nil, fmt.Errorf("%w: tasks[%d].deps: %w", ErrInvalidTaskFile, i, err)
+		}
+	}
+	result := NewExecutableTasks(tasks, envFromTaskFile(f.Env, f.envOrder), f.Entrypoint...)
+	if _, err := result.Plan(); err != nil {
+		return nil, fmt.Errorf("%w: entrypoint: %w", ErrInvalidTaskFile, err)
+	}
+	return result, nil
+}
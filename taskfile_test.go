@@ -0,0 +1,322 @@
+package execx_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+const taskFileYAML = `env:
+  TARGET: app
+entrypoint:
+  - deploy env=prod
+tasks:
+  - name: build
+    description: build the app
+    script: echo "build $TARGET"
+  - name: deploy
+    script: echo "deploy $TARGET to $env $MODE"
+    deps: [build]
+    env:
+      MODE: fast
+    params:
+      - name: env
+        required: true
+  - name: py
+    interpreter: python3
+    script: print("py")
+`
+
+const taskFileJSON = `{
+  "env": {"TARGET": "app"},
+  "entrypoint": ["deploy env=prod"],
+  "tasks": [
+    {"name": "build", "description": "build the app", "script": "echo \"build $TARGET\""},
+    {
+      "name": "deploy",
+      "script": "echo \"deploy $TARGET to $env $MODE\"",
+      "deps": ["build"],
+      "env": {"MODE": "fast"},
+      "params": [{"name": "env", "required": true}]
+    },
+    {"name": "py", "interpreter": "python3", "script": "print(\"py\")"}
+  ]
+}`
+
+const taskFileTOML = `entrypoint = ["deploy env=prod"]
+
+[env]
+TARGET = "app"
+
+[[tasks]]
+name = "build"
+description = "build the app"
+script = 'echo "build $TARGET"'
+
+[[tasks]]
+name = "deploy"
+script = 'echo "deploy $TARGET to $env $MODE"'
+deps = ["build"]
+env = { MODE = "fast" }
+params = [{ name = "env", required = true }]
+
+[[tasks]]
+name = "py"
+interpreter = "python3"
+script = 'print("py")'
+`
+
+func TestReadTaskFile(t *testing.T) {
+	for _, tc := range []struct {
+		format  execx.TaskFileFormat
+		content string
+	}{
+		{format: execx.TaskFileYAML, content: taskFileYAML},
+		{format: execx.TaskFileJSON, content: taskFileJSON},
+		{format: execx.TaskFileTOML, content: taskFileTOML},
+	} {
+		t.Run(string(tc.format), func(t *testing.T) {
+			tasks, err := execx.ReadTaskFile(strings.NewReader(tc.content), tc.format)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, []string{"deploy env=prod"}, tasks.Entrypoint)
+			v, _ := tasks.Env.Get("TARGET")
+			assert.Equal(t, "app", v)
+
+			build, ok := tasks.Tasks.Get("build")
+			assert.True(t, ok)
+			assert.Equal(t, "build the app", build.Description)
+			deploy, ok := tasks.Tasks.Get("deploy")
+			assert.True(t, ok)
+			assert.Equal(t, []string{"build"}, deploy.Deps)
+			assert.Equal(t, []execx.TaskParam{{Name: "env", Required: true}}, deploy.Params)
+			py, ok := tasks.Tasks.Get("py")
+			assert.True(t, ok)
+			if assert.NotNil(t, py.Interpreter) {
+				assert.Equal(t, ".py", py.Interpreter.Extension)
+			}
+
+			requireCommand(t, "sh")
+			got, err := runScriptStdout(t, tasks.IntoScript("sh"))
+			assert.Nil(t, err)
+			assert.Equal(t, "build app\ndeploy app to prod fast\n", got)
+		})
+	}
+}
+
+func TestReadTaskFileInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		title   string
+		format  execx.TaskFileFormat
+		content string
+		want    []string
+	}{
+		{
+			title:   "syntax",
+			format:  execx.TaskFileJSON,
+			content: `{"tasks": [}`,
+		},
+		{
+			title:   "unknown field yaml",
+			format:  execx.TaskFileYAML,
+			content: "tasks:\n  - name: a\n    scripts: echo\n",
+			want:    []string{"scripts"},
+		},
+		{
+			title:   "unknown field json",
+			format:  execx.TaskFileJSON,
+			content: `{"task": []}`,
+			want:    []string{"task"},
+		},
+		{
+			title:   "unknown field toml",
+			format:  execx.TaskFileTOML,
+			content: "[[tasks]]\nname = 'a'\nscripts = 'echo'\n",
+			want:    []string{"tasks.scripts"},
+		},
+		{
+			title:  "schema",
+			format: execx.TaskFileYAML,
+			content: `tasks:
+  - script: echo
+  - name: a-b
+  - name: c
+    deps: [x]
+    params:
+      - name: p
+      - name: p
+      - default: v
+  - name: c
+`,
+			want: []string{
+				"tasks[0].name: required",
+				`tasks[1].name: invalid name "a-b"`,
+				"tasks[2].deps[0]: unknown task x",
+				"tasks[2].params[1].name: duplicated name p",
+				"tasks[2].params[2].name: required",
+				"tasks[3].name: duplicated name c, see tasks[2]",
+			},
+		},
+		{
+			title:  "params",
+			format: execx.TaskFileYAML,
+			content: `env:
+  target: dev
+tasks:
+  - name: a
+    env: {mode: fast}
+    params:
+      - name: target
+      - name: mode
+      - name: p
+        default: d
+        required: true
+`,
+			want: []string{
+				"tasks[0].params[0].name: target conflicts with env.target",
+				"tasks[0].params[1].name: mode conflicts with tasks[0].env.mode",
+				"tasks[0].params[2]: required parameter with default",
+			},
+		},
+		{
+			title:   "cycle",
+			format:  execx.TaskFileYAML,
+			content: "tasks:\n  - {name: a, deps: [b]}\n  - {name: b, deps: [a]}\n",
+			want:    []string{"tasks[0].deps: TaskCycle: a -> b -> a"},
+		},
+		{
+			title:   "entrypoint",
+			format:  execx.TaskFileYAML,
+			content: "entrypoint: [a x=1]\ntasks:\n  - {name: a, params: [{name: p}]}\n",
+			want:    []string{"entrypoint", "unknown parameter x"},
+		},
+		{
+			title:   "unsupported format",
+			format:  execx.TaskFileFormat("ini"),
+			content: "",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := execx.ReadTaskFile(strings.NewReader(tc.content), tc.format)
+			if !assert.NotNil(t, err) {
+				return
+			}
+			if tc.format == "ini" {
+				assert.ErrorIs(t, err, execx.ErrUnsupportedFormat)
+			} else {
+				assert.ErrorIs(t, err, execx.ErrInvalidTaskFile)
+			}
+			for _, w := range tc.want {
+				assert.Contains(t, err.Error(), w)
+			}
+		})
+	}
+}
+
+func TestReadTaskFileEnv(t *testing.T) {
+	for _, tc := range []struct {
+		title   string
+		format  execx.TaskFileFormat
+		content string
+		want    []string
+		// keys of the env of the first task
+		taskWant []string
+	}{
+		{
+			title:    "yaml in order",
+			format:   execx.TaskFileYAML,
+			content:  "env:\n  B: 1\n  A: $B\n  C: 3\ntasks:\n  - name: a\n    env: {Z: 1, Y: 2}\n",
+			want:     []string{"B=1", "A=$B", "C=3"},
+			taskWant: []string{"Z", "Y"},
+		},
+		{
+			title:  "yaml merge keys",
+			format: execx.TaskFileYAML,
+			content: `tasks:
+  - name: a
+    env: &base
+      Y: 1
+      X: 2
+env:
+  B: 1
+  <<: *base
+  A: 2
+`,
+			want:     []string{"B=1", "A=2", "X=2", "Y=1"},
+			taskWant: []string{"Y", "X"},
+		},
+		{
+			title:    "json sorted",
+			format:   execx.TaskFileJSON,
+			content:  `{"env": {"B": "1", "A": "2", "C": "3"}, "tasks": [{"name": "a", "env": {"Z": "1", "Y": "2"}}]}`,
+			want:     []string{"A=2", "B=1", "C=3"},
+			taskWant: []string{"Y", "Z"},
+		},
+		{
+			title:    "toml sorted",
+			format:   execx.TaskFileTOML,
+			content:  "[env]\nB = '1'\nA = '2'\nC = '3'\n[[tasks]]\nname = 'a'\nenv = { Z = '1', Y = '2' }\n",
+			want:     []string{"A=2", "B=1", "C=3"},
+			taskWant: []string{"Y", "Z"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			tasks, err := execx.ReadTaskFile(strings.NewReader(tc.content), tc.format)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.want, tasks.Env.IntoSlice())
+			task, ok := tasks.Tasks.Get("a")
+			if assert.True(t, ok) {
+				assert.Equal(t, tc.taskWant, task.Env.Keys())
+			}
+		})
+	}
+
+	t.Run("invalid names in order", func(t *testing.T) {
+		const content = `{"env": {"c-1": "", "b-1": "", "a-1": ""}, "tasks": [{"name": "a", "env": {"z-1": "", "y-1": ""}}]}`
+		want := strings.Join([]string{
+			"InvalidTaskFile: env.a-1: invalid name",
+			"InvalidTaskFile: env.b-1: invalid name",
+			"InvalidTaskFile: env.c-1: invalid name",
+			"InvalidTaskFile: tasks[0].env.y-1: invalid name",
+			"InvalidTaskFile: tasks[0].env.z-1: invalid name",
+		}, "\n")
+		for range 10 {
+			_, err := execx.ReadTaskFile(strings.NewReader(content), execx.TaskFileJSON)
+			assert.ErrorIs(t, err, execx.ErrInvalidTaskFile)
+			assert.EqualError(t, err, want)
+		}
+	})
+}
+
+func TestLoadTaskFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name    string
+		content string
+	}{
+		{name: "tasks.yml", content: taskFileYAML},
+		{name: "tasks.yaml", content: taskFileYAML},
+		{name: "tasks.json", content: taskFileJSON},
+		{name: "tasks.toml", content: taskFileTOML},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			assert.Nil(t, os.WriteFile(path, []byte(tc.content), 0600))
+			tasks, err := execx.LoadTaskFile(path)
+			assert.Nil(t, err)
+			assert.Len(t, tasks.Tasks, 3)
+		})
+	}
+
+	t.Run("unknown extension", func(t *testing.T) {
+		_, err := execx.LoadTaskFile(filepath.Join(dir, "tasks.ini"))
+		assert.ErrorIs(t, err, execx.ErrUnsupportedFormat)
+	})
+}
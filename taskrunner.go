@@ -0,0 +1,173 @@
+package execx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// TaskRunner runs tasks in order of the dependencies, each task as its own script process.
+//
+// The script of a task contains all the tasks, so tasks can call each other as functions.
+type TaskRunner struct {
+	Tasks Tasks
+	Env   Env
+	// Shell executes the tasks, default is sh.
+	Shell []string
+	// Options are passed to [Cmd.Run] of each task.
+	// Consumers are called concurrently if Parallelism is greater than 1.
+	Options []Option
+	// Parallelism is the maximum number of the tasks running at the same time, default is 1.
+	Parallelism int
+	// If ContinueOnError is true, then run the tasks independent of the failed tasks,
+	// otherwise cancel the running tasks and stop at the first failure.
+	ContinueOnError bool
+}
+
+func NewTaskRunner(tasks Tasks, env Env) *TaskRunner {
+	return &TaskRunner{
+		Tasks: tasks,
+		Env:   env,
+		Shell: []string{"sh"},
+	}
+}
+
+// TaskReport is the result of [TaskRunner.Run].
+type TaskReport struct {
+	// Ran are the names of the executed tasks in order of start, including the failed tasks.
+	Ran []string
+	// Results are the results of the tasks and their dependencies keyed by the names.
+	Results map[string]*TaskResult
+}
+
+// TaskResult is the result of a task.
+type TaskResult struct {
+	// Result is not nil if the task succeeded.
+	Result *Result
+	Err    error
+	// Skipped is true if the task did not run because of the failures of the other tasks.
+	Skipped bool
+}
+
+type taskDone struct {
+	task   *Task
+	result *Result
+	err    error
+}
+
+// Run executes the tasks and their dependencies, each task at most once.
+//
+// A task starts after its dependencies succeed.
+// Returns the errors of the failed tasks joined.
+func (r *TaskRunner) Run(ctx context.Context, name ...string) (*TaskReport, error) {
+	tasks, err := r.Tasks.Resolve(name...)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if err := task.CheckArgs(); err != nil {
+			return nil, err
+		}
+	}
+	if err := (ExecutableTasks{Tasks: r.Tasks, Env: r.Env}).checkParamEnv(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		report = &TaskReport{
+			Results: map[string]*TaskResult{},
+		}
+		limit   = max(r.Parallelism, 1)
+		pending = tasks
+		running int
+		stopped bool
+		doneC   = make(chan taskDone)
+		errs    []error
+	)
+	// ready returns true if the dependencies of the task finished, false if it should wait
+	ready := func(task *Task) (bool, bool) {
+		skip := false
+		for _, d := range task.Deps {
+			x, ok := report.Results[d]
+			if !ok {
+				return false, false
+			}
+			skip = skip || x.Err != nil || x.Skipped
+		}
+		return true, skip
+	}
+	dispatch := func() {
+		var rest Tasks
+		for _, task := range pending {
+			if stopped {
+				report.Results[task.Name] = &TaskResult{Skipped: true}
+				continue
+			}
+			ok, skip := ready(task)
+			switch {
+			case !ok:
+				rest = append(rest, task)
+			case skip:
+				report.Results[task.Name] = &TaskResult{Skipped: true}
+			case running < limit:
+				running++
+				report.Ran = append(report.Ran, task.Name)
+				go func() {
+					result, err := r.runTask(ctx, task)
+					doneC <- taskDone{
+						task:   task,
+						result: result,
+						err:    err,
+					}
+				}()
+			default:
+				rest = append(rest, task)
+			}
+		}
+		pending = rest
+	}
+
+	for dispatch(); running > 0; dispatch() {
+		x := <-doneC
+		running--
+		result := &TaskResult{
+			Result: x.result,
+			Err:    x.err,
+		}
+		report.Results[x.task.Name] = result
+		if x.err != nil {
+			errs = append(errs, fmt.Errorf("%w: task %s", x.err, x.task.Name))
+			if !r.ContinueOnError {
+				stopped = true
+				cancel()
+			}
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+func (r *TaskRunner) script(task *Task) *Script {
+	t := ExecutableTasks{
+		Tasks: r.Tasks,
+		Env:   r.Env,
+	}
+	content, sourceMap := t.render(false, []string{task.Name})
+	shell := r.Shell
+	if len(shell) == 0 {
+		shell = []string{"sh"}
+	}
+	return t.newScript(content, sourceMap, shell[0], shell[1:]...)
+}
+
+func (r *TaskRunner) runTask(ctx context.Context, task *Task) (*Result, error) {
+	var result *Result
+	err := r.script(task).Runner(func(cmd *Cmd) error {
+		x, err := cmd.Run(ctx, r.Options...)
+		result = x
+		return err
+	})
+	return result, err
+}
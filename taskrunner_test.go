@@ -0,0 +1,166 @@
+package execx_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRunner(t *testing.T) {
+	requireCommand(t, "sh")
+
+	newRunner := func(tasks execx.Tasks) (*execx.TaskRunner, *[]string) {
+		var out []string
+		r := execx.NewTaskRunner(tasks, execx.EnvFromSlice([]string{"V=v"}))
+		r.Options = []execx.Option{
+			execx.WithStdoutConsumer(func(x execx.Token) {
+				out = append(out, strings.TrimSpace(x.String()))
+			}),
+		}
+		return r, &out
+	}
+
+	t.Run("dependencies once", func(t *testing.T) {
+		r, out := newRunner(newDependentTasks())
+		report, err := r.Run(context.TODO(), "test", "lint")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"deps", "generate", "build", "test", "lint"}, report.Ran)
+		assert.Equal(t, []string{"deps", "generate", "build", "test", "lint"}, *out)
+	})
+
+	t.Run("call other tasks and env", func(t *testing.T) {
+		r, out := newRunner(execx.NewTasks().
+			Add(execx.NewTask("f", `echo "f $1 $V"`)).
+			Add(execx.NewTask("g", `f g`)))
+		report, err := r.Run(context.TODO(), "g")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"g"}, report.Ran)
+		assert.Equal(t, []string{"f g v"}, *out)
+	})
+
+	t.Run("stop at failure", func(t *testing.T) {
+		r, out := newRunner(execx.NewTasks().
+			Add(execx.NewTask("a", `echo a`)).
+			Add(execx.NewTask("b", `exit 1`, "a")).
+			Add(execx.NewTask("c", `echo c`, "b")))
+		report, err := r.Run(context.TODO(), "c")
+		assert.ErrorContains(t, err, "task b")
+		assert.Equal(t, []string{"a", "b"}, report.Ran)
+		assert.Equal(t, []string{"a"}, *out)
+		assert.NotNil(t, report.Results["a"].Result)
+		assert.NotNil(t, report.Results["b"].Err)
+		assert.True(t, report.Results["c"].Skipped)
+	})
+
+	t.Run("resolve error", func(t *testing.T) {
+		r, _ := newRunner(newDependentTasks())
+		_, err := r.Run(context.TODO(), "unknown")
+		assert.ErrorIs(t, err, execx.ErrTaskNotFound)
+	})
+}
+
+func TestTaskRunnerParallel(t *testing.T) {
+	requireCommand(t, "sh")
+
+	// waitFile waits until the file exists, gives up after about 10 seconds not to hang
+	waitFile := func(path string) string {
+		return fmt.Sprintf(`i=0
+while [ ! -e %[1]s ] && [ $i -lt 100 ] ; do
+  sleep 0.1
+  i=$((i+1))
+done`, path)
+	}
+
+	// Each task records the number of the running tasks after the first $P tasks started.
+	// The number never exceeds $P, and the first task to record sees all the first $P tasks running.
+	countTask := func(name string, deps ...string) *execx.Task {
+		return execx.NewTask(name, fmt.Sprintf(`touch "$DIR/running/%[1]s" "$DIR/started/%[1]s"
+i=0
+while [ "$(ls "$DIR/started" | wc -l)" -lt $P ] && [ $i -lt 100 ] ; do
+  sleep 0.1
+  i=$((i+1))
+done
+ls "$DIR/running" | wc -l >> "$DIR/counts"
+rm "$DIR/running/%[1]s"`, name), deps...)
+	}
+	countTasks := execx.NewTasks().
+		Add(countTask("a")).
+		Add(countTask("b")).
+		Add(countTask("c")).
+		Add(countTask("d")).
+		Add(execx.NewTask("all", "echo all", "a", "b", "c", "d"))
+
+	for _, parallelism := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("parallelism %d", parallelism), func(t *testing.T) {
+			dir := t.TempDir()
+			for _, x := range []string{"running", "started"} {
+				assert.Nil(t, os.Mkdir(filepath.Join(dir, x), 0700))
+			}
+			r := execx.NewTaskRunner(countTasks, execx.EnvFromPairs(
+				"DIR", dir,
+				"P", strconv.Itoa(parallelism),
+			))
+			r.Parallelism = parallelism
+			report, err := r.Run(context.TODO(), "all")
+			assert.Nil(t, err)
+			assert.Len(t, report.Ran, 5)
+			assert.Equal(t, "all", report.Ran[4], "dependencies first")
+
+			b, err := os.ReadFile(filepath.Join(dir, "counts"))
+			if !assert.Nil(t, err) {
+				return
+			}
+			counts := strings.Fields(string(b))
+			assert.Len(t, counts, 4)
+			var maxRunning int
+			for _, x := range counts {
+				n, err := strconv.Atoi(x)
+				assert.Nil(t, err)
+				maxRunning = max(maxRunning, n)
+			}
+			assert.Equal(t, parallelism, maxRunning, "max number of the running tasks")
+		})
+	}
+
+	newFailTasks := func(slow string) execx.Tasks {
+		return execx.NewTasks().
+			Add(execx.NewTask("fail", `touch "$DIR/failed"; exit 1`)).
+			Add(execx.NewTask("slow", slow)).
+			Add(execx.NewTask("after_fail", "echo after_fail", "fail")).
+			Add(execx.NewTask("after_slow", "echo after_slow", "slow"))
+	}
+
+	t.Run("fail fast", func(t *testing.T) {
+		// slow runs until canceled
+		r := execx.NewTaskRunner(newFailTasks(waitFile(`"$DIR/never"`)), execx.EnvFromPairs("DIR", t.TempDir()))
+		r.Parallelism = 2
+		report, err := r.Run(context.TODO(), "after_fail", "after_slow")
+		assert.ErrorContains(t, err, "task fail")
+		assert.ElementsMatch(t, []string{"fail", "slow"}, report.Ran)
+		assert.NotNil(t, report.Results["fail"].Err)
+		assert.NotNil(t, report.Results["slow"].Err, "slow should be canceled")
+		assert.True(t, report.Results["after_fail"].Skipped)
+		assert.True(t, report.Results["after_slow"].Skipped)
+	})
+
+	t.Run("continue on error", func(t *testing.T) {
+		// slow finishes after fail failed
+		r := execx.NewTaskRunner(newFailTasks(waitFile(`"$DIR/failed"`)), execx.EnvFromPairs("DIR", t.TempDir()))
+		r.Parallelism = 2
+		r.ContinueOnError = true
+		report, err := r.Run(context.TODO(), "after_fail", "after_slow")
+		assert.ErrorContains(t, err, "task fail")
+		assert.NotContains(t, err.Error(), "task slow")
+		assert.ElementsMatch(t, []string{"fail", "slow", "after_slow"}, report.Ran)
+		assert.Nil(t, report.Results["slow"].Err)
+		assert.NotNil(t, report.Results["after_slow"].Result)
+		assert.True(t, report.Results["after_fail"].Skipped)
+	})
+}
@@ -0,0 +1,40 @@
+package execx
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/berquerant/execx/internal"
+)
+
+// TemplateFuncs returns the default functions for script templates.
+//
+//	quote s           quote s for POSIX shells by [Quote]
+//	quoteArgs list    quote elements of list by [QuoteArgs]
+//	heredoc s         here-document of s by [Heredoc]
+//	join sep list     join elements of list by sep
+//	indent n s        indent lines of s by n spaces
+//	env key           value of the variable in env, error if it does not exist
+func TemplateFuncs(env Env) template.FuncMap {
+	return template.FuncMap{
+		"quote": Quote,
+		"quoteArgs": func(args []string) string {
+			return QuoteArgs(args...)
+		},
+		"heredoc": Heredoc,
+		"join": func(sep string, list []string) string {
+			return strings.Join(list, sep)
+		},
+		"indent": func(n int, s string) string {
+			return internal.IndentN(s, n)
+		},
+		"env": func(key string) (string, error) {
+			v, ok := env.Get(key)
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrEnvNotFound, key)
+			}
+			return v, nil
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package execx
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// TokenTransform converts a token before it is passed to consumers.
+type TokenTransform func(Token) Token
+
+// NopTokenTransform returns the token as it is.
+func NopTokenTransform(t Token) Token {
+	return t
+}
+
+// ChainTokenTransforms creates a [TokenTransform] that applies transforms in order.
+func ChainTokenTransforms(transform ...TokenTransform) TokenTransform {
+	return func(t Token) Token {
+		for _, f := range transform {
+			t = f(t)
+		}
+		return t
+	}
+}
+
+var (
+	// CSI sequences (e.g. colors, cursor movements),
+	// OSC sequences terminated by BEL or ST,
+	// character set selections and other 2 bytes escape sequences.
+	ansiRegex = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[()][0-9A-Za-z]|\x1b[@-Z\\-_]`)
+)
+
+// StripANSI removes ANSI/VT100 escape sequences from the token.
+func StripANSI(t Token) Token {
+	return token(ansiRegex.ReplaceAll(t.Bytes(), nil))
+}
+
+// CollapseCarriageReturn emulates carriage returns in the token
+// and returns the final visible line.
+//
+// Each segment following '\r' overwrites the line from the beginning,
+// e.g. "progress 10%\rprogress 100%" becomes "progress 100%".
+func CollapseCarriageReturn(t Token) Token {
+	b := t.Bytes()
+	if !bytes.ContainsRune(b, '\r') {
+		return t
+	}
+
+	var line []byte
+	for i, segment := range bytes.Split(b, []byte("\r")) {
+		if i == 0 {
+			line = append(line, segment...)
+			continue
+		}
+		if len(segment) >= len(line) {
+			line = append(line[:0], segment...)
+			continue
+		}
+		copy(line, segment)
+	}
+	return token(line)
+}
@@ -0,0 +1,110 @@
+package execx_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+type stringToken string
+
+func (s stringToken) String() string { return string(s) }
+func (s stringToken) Bytes() []byte  { return []byte(s) }
+
+func TestTokenTransform(t *testing.T) {
+	for _, tc := range []struct {
+		title     string
+		transform execx.TokenTransform
+		input     string
+		want      string
+	}{
+		{
+			title:     "nop",
+			transform: execx.NopTokenTransform,
+			input:     "\x1b[31mred\x1b[0m",
+			want:      "\x1b[31mred\x1b[0m",
+		},
+		{
+			title:     "strip color",
+			transform: execx.StripANSI,
+			input:     "\x1b[31mred\x1b[0m and \x1b[1;32mbold green\x1b[m",
+			want:      "red and bold green",
+		},
+		{
+			title:     "strip cursor movement",
+			transform: execx.StripANSI,
+			input:     "\x1b[2K\x1b[1Gdone",
+			want:      "done",
+		},
+		{
+			title:     "strip osc",
+			transform: execx.StripANSI,
+			input:     "\x1b]0;title\x07text\x1b]8;;http://example.com\x1b\\link",
+			want:      "textlink",
+		},
+		{
+			title:     "no cr",
+			transform: execx.CollapseCarriageReturn,
+			input:     "line",
+			want:      "line",
+		},
+		{
+			title:     "progress",
+			transform: execx.CollapseCarriageReturn,
+			input:     "progress 10%\rprogress 50%\rprogress 100%",
+			want:      "progress 100%",
+		},
+		{
+			title:     "overwrite partially",
+			transform: execx.CollapseCarriageReturn,
+			input:     "abcdef\rxy",
+			want:      "xycdef",
+		},
+		{
+			title:     "trailing cr",
+			transform: execx.CollapseCarriageReturn,
+			input:     "line\r",
+			want:      "line",
+		},
+		{
+			title: "chain",
+			transform: execx.ChainTokenTransforms(
+				execx.StripANSI,
+				execx.CollapseCarriageReturn,
+			),
+			input: "\x1b[32m10%\x1b[0m\r\x1b[32m100%\x1b[0m",
+			want:  "100%",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.transform(stringToken(tc.input)).String())
+		})
+	}
+
+	t.Run("Run", func(t *testing.T) {
+		const input = "\x1b[32m10%\x1b[0m\r\x1b[32m100%\x1b[0m\ndone\n"
+		var (
+			stdout bytes.Buffer
+			got    []string
+		)
+		c := execx.New("cat", "-")
+		c.Stdin = bytes.NewBufferString(input)
+		c.Stdout = &stdout
+		_, err := c.Run(
+			context.TODO(),
+			execx.WithStdoutConsumer(func(x execx.Token) {
+				got = append(got, x.String())
+			}),
+			execx.WithTokenTransform(execx.ChainTokenTransforms(
+				execx.StripANSI,
+				execx.CollapseCarriageReturn,
+			)),
+		)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"100%", "done"}, got)
+		assert.Equal(t, input, stdout.String(), "raw output")
+	})
+}
@@ -0,0 +1,162 @@
+package execx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSyntax        = errors.New("Syntax")
+	ErrNoSyntaxCheck = errors.New("NoSyntaxCheck")
+)
+
+// SyntaxErrorLine is a location of a syntax error.
+type SyntaxErrorLine struct {
+	// Line is the line number in Content, 0 if the error is outside Content, e.g. in Preamble.
+	Line    int
+	Message string
+	// Source is the location in the task if [Script.SourceMap] is not nil.
+	Source *SourceLocation
+}
+
+// SyntaxError is an error reported by the syntax check of the interpreter.
+type SyntaxError struct {
+	// Lines are the parsed locations, may be empty.
+	Lines []SyntaxErrorLine
+	// Output is the raw output of the syntax check.
+	Output string
+}
+
+func (e *SyntaxError) Error() string {
+	if len(e.Lines) == 0 {
+		return fmt.Sprintf("%s: %s", ErrSyntax, strings.TrimSpace(e.Output))
+	}
+	xs := make([]string, len(e.Lines))
+	for i, x := range e.Lines {
+		if x.Source != nil {
+			xs[i] = fmt.Sprintf("line %d (%s): %s", x.Line, x.Source, x.Message)
+			continue
+		}
+		xs[i] = fmt.Sprintf("line %d: %s", x.Line, x.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrSyntax, strings.Join(xs, "; "))
+}
+
+func (*SyntaxError) Unwrap() error {
+	return ErrSyntax
+}
+
+// Validate checks the syntax of the script by [Interpreter.SyntaxCheck] without execution.
+//
+// Returns a [*SyntaxError] if the check fails, the line numbers are mapped to Content.
+// Returns [ErrNoSyntaxCheck] if SyntaxCheck is empty.
+func (s *Script) Validate(ctx context.Context) error {
+	if len(s.SyntaxCheck) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoSyntaxCheck, s.Shell[0])
+	}
+	content, err := s.renderContent()
+	if err != nil {
+		return err
+	}
+	header := s.renderHeader()
+
+	f, err := newScriptFile(header+content, s.fileOptions())
+	if err != nil {
+		return fmt.Errorf("%w: prepare script file", err)
+	}
+	defer f.close()
+	path, err := filepath.Abs(f.path)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	cmd := New(s.Shell[0], slices.Concat(s.Shell[1:], s.SyntaxCheck, []string{path})...)
+	// the arguments are not variables
+	cmd.rawArgs = make([]int, len(cmd.Args))
+	for i := range cmd.rawArgs {
+		cmd.rawArgs[i] = i
+	}
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Env.Merge(s.Env)
+	if _, err := cmd.Run(ctx); err != nil {
+		if _, ok := errors.AsType[*exec.ExitError](err); !ok {
+			return fmt.Errorf("%w: syntax check", err)
+		}
+		lines := parseSyntaxErrorLines(out.String(), path, strings.Count(header, "\n"))
+		for i, x := range lines {
+			if loc, ok := s.SourceMap.Lookup(x.Line); ok {
+				lines[i].Source = &loc
+			}
+		}
+		return &SyntaxError{
+			Lines:  lines,
+			Output: out.String(),
+		}
+	}
+	return nil
+}
+
+// syntaxErrorLocationPatterns are the locations of errors in the outputs of the interpreters,
+// PATH is replaced with the script file.
+var syntaxErrorLocationPatterns = []string{
+	`File "PATH", line (\d+),?`,       // python
+	`(?: at )?PATH:? line (\d+)[:,]?`, // bash, perl
+	`PATH: (\d+):`,                    // dash
+	`PATH:(\d+)(?::\d+)?:?`,           // zsh, ruby, node
+}
+
+var syntaxErrorMessageRegex = regexp.MustCompile(`^\s*\w*Error: `)
+
+// parseSyntaxErrorLines returns the locations in out, offset is the number of lines before Content.
+func parseSyntaxErrorLines(out, path string, offset int) []SyntaxErrorLine {
+	res := make([]*regexp.Regexp, len(syntaxErrorLocationPatterns))
+	for i, p := range syntaxErrorLocationPatterns {
+		res[i] = regexp.MustCompile(strings.ReplaceAll(p, "PATH", regexp.QuoteMeta(path)))
+	}
+
+	var (
+		lines  = strings.Split(out, "\n")
+		result []SyntaxErrorLine
+		seen   = map[int]bool{}
+	)
+	for i, x := range lines {
+		for _, re := range res {
+			m := re.FindStringSubmatchIndex(x)
+			if m == nil {
+				continue
+			}
+			n, _ := strconv.Atoi(x[m[2]:m[3]])
+			n = max(n-offset, 0)
+			if seen[n] {
+				break
+			}
+			seen[n] = true
+			msg := strings.Trim(x[:m[0]]+x[m[1]:], " \t:,")
+			if msg == "" {
+				// the message follows the location, e.g. SyntaxError: invalid syntax
+				for _, y := range lines[i+1:] {
+					if syntaxErrorMessageRegex.MatchString(y) {
+						msg = strings.TrimSpace(y)
+						break
+					}
+				}
+			}
+			result = append(result, SyntaxErrorLine{
+				Line:    n,
+				Message: msg,
+			})
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package execx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/berquerant/execx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptValidate(t *testing.T) {
+	for _, tc := range []struct {
+		title       string
+		interpreter execx.Interpreter
+		shebang     bool
+		content     string
+		wantLine    int
+		wantMessage string
+	}{
+		{
+			title:       "sh",
+			interpreter: execx.InterpreterSh,
+			content:     "echo a\nif then\n",
+			wantLine:    2,
+		},
+		{
+			title:       "sh with shebang",
+			interpreter: execx.InterpreterSh,
+			shebang:     true,
+			content:     "echo a\necho b\nif then\n",
+			wantLine:    3,
+		},
+		{
+			title:       "bash",
+			interpreter: execx.InterpreterBash,
+			content:     "echo a\n\nif then\n",
+			wantLine:    3,
+			wantMessage: "syntax error near unexpected token `then'",
+		},
+		{
+			title:       "python",
+			interpreter: execx.InterpreterPython,
+			content:     "x = 1\ndef f(:\n  pass\n",
+			wantLine:    2,
+			wantMessage: "SyntaxError: invalid syntax",
+		},
+		{
+			title:       "perl",
+			interpreter: execx.InterpreterPerl,
+			content:     "my $x = 1;\nmy $y = ;\n",
+			wantLine:    2,
+		},
+		{
+			title:       "node",
+			interpreter: execx.InterpreterNode,
+			content:     "let a = 1;\nlet = ;\n",
+			wantLine:    2,
+			wantMessage: "SyntaxError: Unexpected token ';'",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			requireCommand(t, tc.interpreter.Shell[0])
+			s := execx.NewScriptWithInterpreter(tc.content, tc.interpreter)
+			s.Shebang = tc.shebang
+
+			err := s.Validate(context.TODO())
+			assert.ErrorIs(t, err, execx.ErrSyntax)
+			var serr *execx.SyntaxError
+			if !assert.True(t, errors.As(err, &serr)) {
+				return
+			}
+			if !assert.NotEmpty(t, serr.Lines, serr.Output) {
+				return
+			}
+			assert.Equal(t, tc.wantLine, serr.Lines[0].Line, serr.Output)
+			if tc.wantMessage != "" {
+				assert.Equal(t, tc.wantMessage, serr.Lines[0].Message, serr.Output)
+			} else {
+				assert.NotEmpty(t, serr.Lines[0].Message, serr.Output)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		for _, s := range []*execx.Script{
+			execx.NewScriptWithInterpreter("echo ok\n", execx.InterpreterSh),
+			execx.NewScriptWithInterpreter("print('ok')\n", execx.InterpreterPython),
+		} {
+			requireCommand(t, s.Shell[0])
+			assert.Nil(t, s.Validate(context.TODO()))
+		}
+	})
+
+	t.Run("no syntax check", func(t *testing.T) {
+		s := execx.NewScriptWithInterpreter("echo ok", execx.Interpreter{
+			Shell: []string{"sh"},
+		})
+		assert.ErrorIs(t, s.Validate(context.TODO()), execx.ErrNoSyntaxCheck)
+	})
+
+	t.Run("template error", func(t *testing.T) {
+		s := execx.NewScriptWithInterpreter("{{if}}", execx.InterpreterSh)
+		s.Template = true
+		err := s.Validate(context.TODO())
+		assert.NotNil(t, err)
+		assert.NotErrorIs(t, err, execx.ErrSyntax)
+	})
+}